@@ -0,0 +1,331 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/projectcalico/calico/app-policy/policystore"
+	"github.com/projectcalico/calico/felix/proto"
+)
+
+// CompiledPolicy is a policy's rule list ([]*proto.Rule), pre-processed
+// into decision structures so that Match doesn't have to linearly rescan
+// every rule's SrcNet/DstNet CIDRs and port ranges for every flow. A
+// CompiledPolicy is immutable once built; recompile it (via
+// CompiledPolicyCache) whenever the PolicyStore generation it was built
+// against moves on.
+type CompiledPolicy struct {
+	namespace  string
+	rules      []*proto.Rule
+	generation uint64
+
+	srcNet  *netIndex
+	dstNet  *netIndex
+	srcPort *portIndex
+	dstPort *portIndex
+}
+
+// CompilePolicy builds a CompiledPolicy from rules, as seen under
+// policyNamespace. generation should be the PolicyStore.Generation() the
+// rules were last synced under, so a CompiledPolicyCache can tell when it
+// needs rebuilding.
+func CompilePolicy(policyNamespace string, rules []*proto.Rule, generation uint64) *CompiledPolicy {
+	n := len(rules)
+	return &CompiledPolicy{
+		namespace:  policyNamespace,
+		rules:      rules,
+		generation: generation,
+		srcNet: buildNetIndex(n, rules,
+			func(r *proto.Rule) []string { return r.SrcNet },
+			func(r *proto.Rule) []string { return r.NotSrcNet }),
+		dstNet: buildNetIndex(n, rules,
+			func(r *proto.Rule) []string { return r.DstNet },
+			func(r *proto.Rule) []string { return r.NotDstNet }),
+		srcPort: buildPortIndex(n, rules, func(r *proto.Rule) []*proto.PortRange { return r.SrcPorts }),
+		dstPort: buildPortIndex(n, rules, func(r *proto.Rule) []*proto.PortRange { return r.DstPorts }),
+	}
+}
+
+// Generation returns the PolicyStore generation this CompiledPolicy was
+// built against.
+func (p *CompiledPolicy) Generation() uint64 { return p.generation }
+
+// Match evaluates the compiled rule list against req, in the policy's
+// original rule order, and returns the action and index of the first rule
+// that fully matches. It returns ok=false if no rule matches.
+//
+// SrcNet/DstNet and port-range candidacy are resolved up front via the
+// compiled indices below in roughly O(log N); the remaining clauses (IP
+// sets, HTTP, JWT, selectors, ...) are still checked per candidate rule via
+// match, in order, so only rules that survive the net/port pre-filter ever
+// pay for the rest of the evaluation.
+func (p *CompiledPolicy) Match(ctx context.Context, req *requestCache) (action string, ruleID int, ok bool) {
+	candidates := p.srcNet.candidates(req.GetSourceIP())
+	candidates = candidates.and(p.dstNet.candidates(req.GetDestIP()))
+	candidates = candidates.and(p.srcPort.candidates(req.GetSourcePort()))
+	candidates = candidates.and(p.dstPort.candidates(req.GetDestPort()))
+
+	for _, i := range candidates.ones() {
+		rule := p.rules[i]
+		if match(ctx, p.namespace, rule, req) {
+			return rule.Action, i, true
+		}
+	}
+	return "", -1, false
+}
+
+// CompiledPolicyCache recompiles CompiledPolicys lazily: a cached entry is
+// reused as long as the PolicyStore's generation hasn't moved on since it
+// was built, and rebuilt from scratch the first time it's asked for after
+// that. It is safe for concurrent use.
+type CompiledPolicyCache struct {
+	mu      sync.Mutex
+	entries map[string]*CompiledPolicy
+}
+
+// NewCompiledPolicyCache creates an empty CompiledPolicyCache.
+func NewCompiledPolicyCache() *CompiledPolicyCache {
+	return &CompiledPolicyCache{entries: make(map[string]*CompiledPolicy)}
+}
+
+// Get returns the CompiledPolicy for key (e.g. "<tier>/<policy name>"),
+// rebuilding it from rules if this is the first request for key or store's
+// generation has advanced since the cached copy was built.
+func (c *CompiledPolicyCache) Get(key, policyNamespace string, rules []*proto.Rule, store *policystore.PolicyStore) *CompiledPolicy {
+	generation := store.Generation()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if p, found := c.entries[key]; found && p.generation == generation {
+		return p
+	}
+	p := CompilePolicy(policyNamespace, rules, generation)
+	c.entries[key] = p
+	return p
+}
+
+// ruleBitset is a fixed-size bitmap over rule indices [0, n), used to carry
+// "which rules are still candidates" through the compiled indices' AND/OR
+// combination in Match.
+type ruleBitset struct {
+	words []uint64
+	n     int
+}
+
+func newRuleBitset(n int) ruleBitset {
+	return ruleBitset{words: make([]uint64, (n+63)/64), n: n}
+}
+
+func newFullRuleBitset(n int) ruleBitset {
+	b := newRuleBitset(n)
+	for i := 0; i < n; i++ {
+		b.set(i)
+	}
+	return b
+}
+
+func (b ruleBitset) set(i int) {
+	b.words[i/64] |= 1 << uint(i%64)
+}
+
+func (b ruleBitset) clone() ruleBitset {
+	words := make([]uint64, len(b.words))
+	copy(words, b.words)
+	return ruleBitset{words: words, n: b.n}
+}
+
+func (b ruleBitset) and(o ruleBitset) ruleBitset {
+	out := b.clone()
+	for i := range out.words {
+		out.words[i] &= o.words[i]
+	}
+	return out
+}
+
+func (b ruleBitset) or(o ruleBitset) ruleBitset {
+	out := b.clone()
+	for i := range out.words {
+		out.words[i] |= o.words[i]
+	}
+	return out
+}
+
+func (b ruleBitset) andNot(o ruleBitset) ruleBitset {
+	out := b.clone()
+	for i := range out.words {
+		out.words[i] &^= o.words[i]
+	}
+	return out
+}
+
+// ones returns the set bit indices in ascending order, which is also the
+// rule list's original, first-match-wins order.
+func (b ruleBitset) ones() []int {
+	var out []int
+	for i := 0; i < b.n; i++ {
+		if b.words[i/64]&(1<<uint(i%64)) != 0 {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// bitsetFromRuleIDs turns the string rule-index ids a NetTrie lookup
+// returns (see buildNetIndex) back into a ruleBitset.
+func bitsetFromRuleIDs(n int, ids []string) ruleBitset {
+	b := newRuleBitset(n)
+	for _, id := range ids {
+		i, err := strconv.Atoi(id)
+		if err != nil {
+			continue
+		}
+		b.set(i)
+	}
+	return b
+}
+
+// netIndex resolves which rules in a compiled policy a candidate address
+// is consistent with, for one of SrcNet/NotSrcNet or DstNet/NotDstNet. It
+// holds one radix trie per polarity, built from the union of every rule's
+// CIDRs, with each CIDR's leaf carrying the indices of the rules that
+// listed it -- so a lookup costs O(prefix length) regardless of how many
+// rules or CIDRs the policy has, rather than rescanning every rule's net
+// list per flow the way matchNet/matchNotNet do.
+type netIndex struct {
+	all         ruleBitset
+	hasPositive ruleBitset
+	hasNegative ruleBitset
+	positive    *policystore.NetTrie
+	negative    *policystore.NetTrie
+}
+
+func buildNetIndex(n int, rules []*proto.Rule, nets, notNets func(*proto.Rule) []string) *netIndex {
+	idx := &netIndex{
+		all:         newFullRuleBitset(n),
+		hasPositive: newRuleBitset(n),
+		hasNegative: newRuleBitset(n),
+		positive:    policystore.NewNetTrie(),
+		negative:    policystore.NewNetTrie(),
+	}
+	for i, r := range rules {
+		if cidrs := nets(r); len(cidrs) > 0 {
+			idx.hasPositive.set(i)
+			for _, cidr := range cidrs {
+				// A CIDR that fails to parse never matches, but doesn't
+				// abort compiling the rest of the policy.
+				_ = idx.positive.Insert(cidr, strconv.Itoa(i))
+			}
+		}
+		if cidrs := notNets(r); len(cidrs) > 0 {
+			idx.hasNegative.set(i)
+			for _, cidr := range cidrs {
+				_ = idx.negative.Insert(cidr, strconv.Itoa(i))
+			}
+		}
+	}
+	return idx
+}
+
+// candidates returns the rules ip is consistent with: a rule with no
+// positive net list passes automatically, one with a list must have ip
+// fall inside one of its CIDRs; a rule with no negated net list passes
+// automatically, one with a list must have ip fall inside none of them.
+func (idx *netIndex) candidates(ip net.IP) ruleBitset {
+	posMatches := bitsetFromRuleIDs(idx.all.n, idx.positive.AllMatches(ip))
+	negMatches := bitsetFromRuleIDs(idx.all.n, idx.negative.AllMatches(ip))
+
+	positiveOK := idx.all.andNot(idx.hasPositive).or(posMatches)
+	return positiveOK.andNot(negMatches)
+}
+
+// portIndex resolves which rules in a compiled policy a candidate port is
+// consistent with, for either SrcPorts or DstPorts. It coordinate-compresses
+// every rule's port ranges into a sorted list of breakpoints and, for each
+// resulting segment, precomputes which rules cover it -- the interval-tree
+// equivalent of netIndex's trie: a lookup is one binary search over the
+// breakpoints rather than a scan of every rule's ranges.
+type portIndex struct {
+	all        ruleBitset
+	hasSpec    ruleBitset
+	boundaries []int        // sorted, deduplicated; segment i covers [boundaries[i], boundaries[i+1])
+	segments   []ruleBitset // len(segments) == len(boundaries)-1
+}
+
+func buildPortIndex(n int, rules []*proto.Rule, ports func(*proto.Rule) []*proto.PortRange) *portIndex {
+	idx := &portIndex{all: newFullRuleBitset(n), hasSpec: newRuleBitset(n)}
+
+	type ruleRange struct{ first, last, rule int }
+	var ranges []ruleRange
+	boundarySet := make(map[int]struct{})
+	for i, r := range rules {
+		rs := ports(r)
+		if len(rs) == 0 {
+			continue
+		}
+		idx.hasSpec.set(i)
+		for _, pr := range rs {
+			first, last := int(pr.First), int(pr.Last)
+			ranges = append(ranges, ruleRange{first, last, i})
+			boundarySet[first] = struct{}{}
+			boundarySet[last+1] = struct{}{}
+		}
+	}
+	if len(ranges) == 0 {
+		return idx
+	}
+
+	boundaries := make([]int, 0, len(boundarySet))
+	for b := range boundarySet {
+		boundaries = append(boundaries, b)
+	}
+	sort.Ints(boundaries)
+	idx.boundaries = boundaries
+
+	idx.segments = make([]ruleBitset, len(boundaries)-1)
+	for s := range idx.segments {
+		idx.segments[s] = newRuleBitset(n)
+	}
+	for _, rr := range ranges {
+		start := sort.SearchInts(boundaries, rr.first)
+		end := sort.SearchInts(boundaries, rr.last+1)
+		for s := start; s < end; s++ {
+			idx.segments[s].set(rr.rule)
+		}
+	}
+	return idx
+}
+
+// candidates returns the rules port is consistent with: a rule with no
+// port-range list passes automatically, one with a list must have port
+// fall inside one of its ranges.
+func (idx *portIndex) candidates(port int) ruleBitset {
+	if len(idx.boundaries) == 0 {
+		return idx.all
+	}
+	seg := sort.SearchInts(idx.boundaries, port+1) - 1
+	var matched ruleBitset
+	if seg < 0 || seg >= len(idx.segments) {
+		matched = newRuleBitset(idx.all.n)
+	} else {
+		matched = idx.segments[seg]
+	}
+	return idx.all.andNot(idx.hasSpec).or(matched)
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	auth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/calico/app-policy/policystore"
+	"github.com/projectcalico/calico/felix/proto"
+	libnet "github.com/projectcalico/calico/libcalico-go/lib/net"
+)
+
+// compiledPolicyTestRequest builds a minimal CheckRequest for a flow from
+// srcIP to dstIP:dstPort over TCP, wrapped in the same
+// CheckRequestToFlowAdapter the real server uses, so CompiledPolicy.Match
+// exercises every Flow accessor match() calls instead of a hand-stubbed
+// subset of them.
+func compiledPolicyTestRequest(store *policystore.PolicyStore, srcIP, dstIP string, dstPort int) *requestCache {
+	req := &auth.CheckRequest{Attributes: &auth.AttributeContext{
+		Source: &auth.AttributeContext_Peer{
+			Address: &core.Address{Address: &core.Address_SocketAddress{
+				SocketAddress: &core.SocketAddress{Address: srcIP, Protocol: core.SocketAddress_TCP},
+			}},
+		},
+		Destination: &auth.AttributeContext_Peer{
+			Address: &core.Address{Address: &core.Address_SocketAddress{
+				SocketAddress: &core.SocketAddress{
+					Address:       dstIP,
+					Protocol:      core.SocketAddress_TCP,
+					PortSpecifier: &core.SocketAddress_PortValue{PortValue: uint32(dstPort)},
+				},
+			}},
+		},
+	}}
+	return NewRequestCache(store, NewCheckRequestToFlowAdapter(req))
+}
+
+func TestNetIndexCandidatesPositiveAndNegative(t *testing.T) {
+	RegisterTestingT(t)
+
+	rules := []*proto.Rule{
+		{},                                   // rule 0: no net restriction at all, always a candidate
+		{SrcNet: []string{"10.0.0.0/24"}},    // rule 1
+		{NotSrcNet: []string{"10.0.0.0/24"}}, // rule 2
+		{SrcNet: []string{"10.0.1.0/24"}},    // rule 3: different subnet
+	}
+	idx := buildNetIndex(len(rules), rules,
+		func(r *proto.Rule) []string { return r.SrcNet },
+		func(r *proto.Rule) []string { return r.NotSrcNet })
+
+	inside := idx.candidates(libnet.ParseIP("10.0.0.5").IP)
+	Expect(inside.ones()).To(Equal([]int{0, 1}))
+
+	outside := idx.candidates(libnet.ParseIP("10.0.2.5").IP)
+	Expect(outside.ones()).To(Equal([]int{0, 2}))
+}
+
+func TestPortIndexCandidatesRangesAndUnrestricted(t *testing.T) {
+	RegisterTestingT(t)
+
+	rules := []*proto.Rule{
+		{}, // rule 0: no port restriction
+		{DstPorts: []*proto.PortRange{{First: 80, Last: 80}}},     // rule 1
+		{DstPorts: []*proto.PortRange{{First: 8000, Last: 9000}}}, // rule 2
+	}
+	idx := buildPortIndex(len(rules), rules, func(r *proto.Rule) []*proto.PortRange { return r.DstPorts })
+
+	Expect(idx.candidates(80).ones()).To(Equal([]int{0, 1}))
+	Expect(idx.candidates(8500).ones()).To(Equal([]int{0, 2}))
+	Expect(idx.candidates(22).ones()).To(Equal([]int{0}))
+}
+
+func TestPortIndexNoRulesRestrictPorts(t *testing.T) {
+	RegisterTestingT(t)
+
+	rules := []*proto.Rule{{}, {}}
+	idx := buildPortIndex(len(rules), rules, func(r *proto.Rule) []*proto.PortRange { return r.DstPorts })
+
+	Expect(idx.candidates(443).ones()).To(Equal([]int{0, 1}))
+}
+
+func TestCompiledPolicyMatchReturnsFirstMatchingRuleInOrder(t *testing.T) {
+	RegisterTestingT(t)
+
+	store := policystore.NewPolicyStore()
+	rules := []*proto.Rule{
+		{Action: "Deny", SrcNet: []string{"10.0.0.0/24"}, DstPorts: []*proto.PortRange{{First: 443, Last: 443}}},
+		{Action: "Allow", SrcNet: []string{"10.0.0.0/16"}},
+		{Action: "Allow"},
+	}
+	policy := CompilePolicy("default", rules, store.Generation())
+
+	req := compiledPolicyTestRequest(store, "10.0.0.5", "192.168.1.1", 443)
+	action, ruleID, ok := policy.Match(context.Background(), req)
+	Expect(ok).To(BeTrue())
+	Expect(ruleID).To(Equal(0))
+	Expect(action).To(Equal("Deny"))
+}
+
+func TestCompiledPolicyMatchSkipsNonCandidateRule(t *testing.T) {
+	RegisterTestingT(t)
+
+	store := policystore.NewPolicyStore()
+	rules := []*proto.Rule{
+		{Action: "Deny", SrcNet: []string{"10.0.0.0/24"}},
+		{Action: "Allow"},
+	}
+	policy := CompilePolicy("default", rules, store.Generation())
+
+	req := compiledPolicyTestRequest(store, "172.16.0.1", "192.168.1.1", 80)
+	action, ruleID, ok := policy.Match(context.Background(), req)
+	Expect(ok).To(BeTrue())
+	Expect(ruleID).To(Equal(1))
+	Expect(action).To(Equal("Allow"))
+}
+
+func TestCompiledPolicyCacheRecompilesOnGenerationChange(t *testing.T) {
+	RegisterTestingT(t)
+
+	store := policystore.NewPolicyStore()
+	rules := []*proto.Rule{{Action: "Allow"}}
+	cache := NewCompiledPolicyCache()
+
+	first := cache.Get("default/allow-all", "default", rules, store)
+	again := cache.Get("default/allow-all", "default", rules, store)
+	Expect(again).To(BeIdenticalTo(first))
+
+	store.Bump()
+	afterBump := cache.Get("default/allow-all", "default", rules, store)
+	Expect(afterBump).NotTo(BeIdenticalTo(first))
+	Expect(afterBump.Generation()).To(Equal(store.Generation()))
+}
@@ -0,0 +1,261 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"container/list"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	decisionCacheHitsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "calico_app_policy_decision_cache_hits_total",
+		Help: "Number of CheckRequests whose allow/deny decision was served from the per-flow decision cache.",
+	})
+	decisionCacheMissesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "calico_app_policy_decision_cache_misses_total",
+		Help: "Number of CheckRequests that required full rule evaluation because the per-flow decision cache had no entry.",
+	})
+	decisionCacheEvictionsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "calico_app_policy_decision_cache_evictions_total",
+		Help: "Number of entries evicted from the per-flow decision cache to stay within its size limit.",
+	})
+	decisionLogSuppressedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "calico_app_policy_decision_log_suppressed_total",
+		Help: "Number of repeated-denial log lines suppressed by the per-5-tuple rate limiter.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		decisionCacheHitsTotal,
+		decisionCacheMissesTotal,
+		decisionCacheEvictionsTotal,
+		decisionLogSuppressedTotal,
+	)
+}
+
+// DecisionKey identifies a flow for the purposes of the decision cache: the
+// (srcIP, dstIP, dstPort, proto) tuple that determines a CheckRequest's
+// outcome, plus the policystore.PolicyStore generation the decision was
+// computed under. srcPort is deliberately excluded -- unlike DstIpPortSetIds
+// and SrcNet/DstNet matching, nothing in rule evaluation looks at the
+// source port, so keying on it would fragment the cache across connections
+// that are otherwise identical for policy purposes.
+type DecisionKey struct {
+	SrcIP      string
+	DstIP      string
+	DstPort    int
+	Protocol   int
+	Generation uint64
+}
+
+// NewDecisionKey builds a DecisionKey from a flow and the generation of the
+// store it was evaluated against.
+func NewDecisionKey(srcIP, dstIP net.IP, dstPort, protocol int, generation uint64) DecisionKey {
+	return DecisionKey{
+		SrcIP:      srcIP.String(),
+		DstIP:      dstIP.String(),
+		DstPort:    dstPort,
+		Protocol:   protocol,
+		Generation: generation,
+	}
+}
+
+// fiveTuple identifies a connection for log rate-limiting: unlike
+// DecisionKey it includes the source port (so distinct connections from
+// the same host don't share a budget) and excludes the store generation
+// (so a policy reload doesn't reset a flood's rate limit).
+type fiveTuple struct {
+	srcIP    string
+	srcPort  int
+	dstIP    string
+	dstPort  int
+	protocol int
+}
+
+// DecisionCache memoizes the final allow/deny outcome of a full rule
+// evaluation, keyed by DecisionKey, so that repeated flows between the same
+// pair of endpoints don't re-run O(rules × nets) matching on every packet.
+// It also gates how often repeated denials for the same connection are
+// logged, via a token-bucket rate limiter keyed by 5-tuple, so a flood of
+// denies from one misbehaving client can't drown out everything else in the
+// logs.
+//
+// DecisionCache is safe for concurrent use.
+type DecisionCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[DecisionKey]*list.Element
+	order    *list.List // front = most recently used
+
+	logRate         float64 // tokens added per second
+	logBurst        float64 // bucket capacity
+	limiterCapacity int
+	limiters        map[fiveTuple]*list.Element
+	limiterOrder    *list.List // front = most recently used
+	now             func() time.Time
+}
+
+type cacheEntry struct {
+	key     DecisionKey
+	allowed bool
+}
+
+type limiterEntry struct {
+	tuple fiveTuple
+	tb    *tokenBucket
+}
+
+// NewDecisionCache creates a DecisionCache holding at most capacity
+// decisions and at most limiterCapacity per-5-tuple rate limiters,
+// rate-limiting repeated-denial logs for a single 5-tuple to
+// logRatePerSecond, with bursts of up to logBurst. Bounding limiterCapacity
+// matters as much as capacity does: without it, a flood of denials from
+// distinct source ports (the exact traffic ShouldLogDenial exists to
+// throttle the logging of) would otherwise grow limiters without bound,
+// turning the mitigation into a memory-exhaustion vector of its own.
+func NewDecisionCache(capacity int, logRatePerSecond float64, logBurst float64, limiterCapacity int) *DecisionCache {
+	return &DecisionCache{
+		capacity:        capacity,
+		entries:         make(map[DecisionKey]*list.Element),
+		order:           list.New(),
+		logRate:         logRatePerSecond,
+		logBurst:        logBurst,
+		limiterCapacity: limiterCapacity,
+		limiters:        make(map[fiveTuple]*list.Element),
+		limiterOrder:    list.New(),
+		now:             time.Now,
+	}
+}
+
+// Get returns the cached decision for key, if any. The generation embedded
+// in key means a decision computed under a stale generation is simply a
+// different, unpopulated key -- Get never needs to check the current
+// generation itself.
+func (c *DecisionCache) Get(key DecisionKey) (allowed bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, found := c.entries[key]
+	if !found {
+		decisionCacheMissesTotal.Inc()
+		return false, false
+	}
+	c.order.MoveToFront(elem)
+	decisionCacheHitsTotal.Inc()
+	return elem.Value.(*cacheEntry).allowed, true
+}
+
+// Put records the outcome of a full rule evaluation for key, evicting the
+// least-recently-used entry if the cache is at capacity.
+func (c *DecisionCache) Put(key DecisionKey, allowed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, found := c.entries[key]; found {
+		elem.Value.(*cacheEntry).allowed = allowed
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	elem := c.order.PushFront(&cacheEntry{key: key, allowed: allowed})
+	c.entries[key] = elem
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		decisionCacheEvictionsTotal.Inc()
+	}
+}
+
+// ShouldLogDenial reports whether a denial of the connection identified by
+// srcIP:srcPort -> dstIP:dstPort/protocol should be logged, consuming one
+// token from that 5-tuple's bucket if so. Repeated denials of the same
+// connection beyond the configured rate are silently counted in
+// calico_app_policy_decision_log_suppressed_total instead of being logged.
+func (c *DecisionCache) ShouldLogDenial(srcIP net.IP, srcPort int, dstIP net.IP, dstPort, protocol int) bool {
+	tuple := fiveTuple{
+		srcIP:    srcIP.String(),
+		srcPort:  srcPort,
+		dstIP:    dstIP.String(),
+		dstPort:  dstPort,
+		protocol: protocol,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.limiters[tuple]
+	var tb *tokenBucket
+	if ok {
+		c.limiterOrder.MoveToFront(elem)
+		tb = elem.Value.(*limiterEntry).tb
+	} else {
+		tb = newTokenBucket(c.logRate, c.logBurst, c.now())
+		elem = c.limiterOrder.PushFront(&limiterEntry{tuple: tuple, tb: tb})
+		c.limiters[tuple] = elem
+
+		if c.limiterOrder.Len() > c.limiterCapacity {
+			oldest := c.limiterOrder.Back()
+			c.limiterOrder.Remove(oldest)
+			delete(c.limiters, oldest.Value.(*limiterEntry).tuple)
+			decisionCacheEvictionsTotal.Inc()
+		}
+	}
+	if tb.take(1, c.now()) {
+		return true
+	}
+	decisionLogSuppressedTotal.Inc()
+	return false
+}
+
+// tokenBucket is a minimal token-bucket rate limiter: tokens accrue at
+// rate per second up to burst, and take consumes n of them if available.
+type tokenBucket struct {
+	rate     float64
+	burst    float64
+	tokens   float64
+	lastFill time.Time
+}
+
+func newTokenBucket(rate, burst float64, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, lastFill: now}
+}
+
+func (b *tokenBucket) take(n float64, now time.Time) bool {
+	elapsed := now.Sub(b.lastFill).Seconds()
+	if elapsed > 0 {
+		b.tokens = minFloat(b.burst, b.tokens+elapsed*b.rate)
+		b.lastFill = now
+	}
+	if b.tokens < n {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
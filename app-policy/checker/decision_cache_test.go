@@ -0,0 +1,121 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDecisionCacheHitAndMiss(t *testing.T) {
+	RegisterTestingT(t)
+
+	c := NewDecisionCache(10, 1, 1, 10)
+	key := NewDecisionKey(net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), 443, 6, 1)
+
+	_, ok := c.Get(key)
+	Expect(ok).To(BeFalse())
+
+	c.Put(key, true)
+	allowed, ok := c.Get(key)
+	Expect(ok).To(BeTrue())
+	Expect(allowed).To(BeTrue())
+}
+
+func TestDecisionCacheGenerationChangeMisses(t *testing.T) {
+	RegisterTestingT(t)
+
+	c := NewDecisionCache(10, 1, 1, 10)
+	src, dst := net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")
+
+	c.Put(NewDecisionKey(src, dst, 443, 6, 1), false)
+
+	// Same flow, later generation: the old, stale decision must not leak
+	// through under the new generation's key.
+	_, ok := c.Get(NewDecisionKey(src, dst, 443, 6, 2))
+	Expect(ok).To(BeFalse())
+}
+
+func TestDecisionCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	RegisterTestingT(t)
+
+	c := NewDecisionCache(2, 1, 1, 10)
+	src := net.ParseIP("10.0.0.1")
+	keyA := NewDecisionKey(src, net.ParseIP("10.0.0.10"), 80, 6, 1)
+	keyB := NewDecisionKey(src, net.ParseIP("10.0.0.11"), 80, 6, 1)
+	keyC := NewDecisionKey(src, net.ParseIP("10.0.0.12"), 80, 6, 1)
+
+	c.Put(keyA, true)
+	c.Put(keyB, true)
+	// Touch A so B becomes the least-recently-used entry.
+	c.Get(keyA)
+	c.Put(keyC, true)
+
+	_, ok := c.Get(keyB)
+	Expect(ok).To(BeFalse())
+	_, ok = c.Get(keyA)
+	Expect(ok).To(BeTrue())
+	_, ok = c.Get(keyC)
+	Expect(ok).To(BeTrue())
+}
+
+func TestDecisionCacheShouldLogDenialRateLimits(t *testing.T) {
+	RegisterTestingT(t)
+
+	c := NewDecisionCache(10, 1, 2, 10)
+	now := time.Unix(0, 0)
+	c.now = func() time.Time { return now }
+
+	src, dst := net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")
+
+	// Burst of 2 tokens: the first two denials log, the third is
+	// suppressed.
+	Expect(c.ShouldLogDenial(src, 1234, dst, 443, 6)).To(BeTrue())
+	Expect(c.ShouldLogDenial(src, 1234, dst, 443, 6)).To(BeTrue())
+	Expect(c.ShouldLogDenial(src, 1234, dst, 443, 6)).To(BeFalse())
+
+	// A different source port is a different connection with its own
+	// budget.
+	Expect(c.ShouldLogDenial(src, 5678, dst, 443, 6)).To(BeTrue())
+
+	// After a second passes, the bucket refills at 1 token/sec.
+	now = now.Add(time.Second)
+	Expect(c.ShouldLogDenial(src, 1234, dst, 443, 6)).To(BeTrue())
+	Expect(c.ShouldLogDenial(src, 1234, dst, 443, 6)).To(BeFalse())
+}
+
+func TestDecisionCacheLimitersEvictLeastRecentlyUsed(t *testing.T) {
+	RegisterTestingT(t)
+
+	// A flood of denials from distinct source ports (e.g. a port scanner)
+	// must not grow the limiters map without bound: once limiterCapacity
+	// distinct 5-tuples have been seen, the least-recently-used one is
+	// evicted to make room for a new one.
+	c := NewDecisionCache(10, 1, 1, 2)
+	dst := net.ParseIP("10.0.0.2")
+	src := net.ParseIP("10.0.0.1")
+
+	c.ShouldLogDenial(src, 1, dst, 443, 6)
+	c.ShouldLogDenial(src, 2, dst, 443, 6)
+	Expect(c.limiters).To(HaveLen(2))
+
+	c.ShouldLogDenial(src, 3, dst, 443, 6)
+	Expect(c.limiters).To(HaveLen(2))
+	Expect(c.limiters).ToNot(HaveKey(fiveTuple{srcIP: src.String(), srcPort: 1, dstIP: dst.String(), dstPort: 443, protocol: 6}))
+	Expect(c.limiters).To(HaveKey(fiveTuple{srcIP: src.String(), srcPort: 3, dstIP: dst.String(), dstPort: 443, protocol: 6}))
+}
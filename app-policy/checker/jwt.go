@@ -0,0 +1,182 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/projectcalico/calico/app-policy/policystore"
+	"github.com/projectcalico/calico/felix/proto"
+)
+
+// ValidateJWTMatch checks that jwtMatch's claim paths are well formed and
+// that every regex in it compiles, warming store's compiled-regex cache
+// (see compiledRegex) used by matchJWT. It should be called with the store
+// a policy update is being applied to, so a bad path or regex is rejected
+// as an update error rather than surfacing as an InvalidDataFromDataPlane
+// panic during matching.
+func ValidateJWTMatch(store *policystore.PolicyStore, jwtMatch *proto.JWTMatch) error {
+	if jwtMatch == nil {
+		return nil
+	}
+	for _, c := range jwtMatch.Claims {
+		if err := validateClaimPath(c.Path); err != nil {
+			return err
+		}
+		if pattern := c.GetRegex(); pattern != "" {
+			if _, err := compiledRegex(store, pattern); err != nil {
+				return fmt.Errorf("invalid JWT claim regex for %q: %q: %w", c.Path, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateClaimPath(path string) error {
+	if path == "" {
+		return fmt.Errorf("JWT claim match has an empty path")
+	}
+	for _, seg := range strings.Split(path, ".") {
+		if seg == "" {
+			return fmt.Errorf("invalid JWT claim path %q: empty path segment", path)
+		}
+	}
+	return nil
+}
+
+// matchJWT returns true if jwtMatch is nil (an omitted JWT match clause
+// always matches), or the issuer/audience/claims predicates it specifies
+// are all satisfied by the verified JWT payload attached to req.
+func matchJWT(jwtMatch *proto.JWTMatch, req *requestCache) bool {
+	if jwtMatch == nil {
+		return true
+	}
+	claims := req.jwtClaimsCached()
+	if claims == nil {
+		return false
+	}
+	if jwtMatch.Issuer != "" && claims.GetFields()["iss"].GetStringValue() != jwtMatch.Issuer {
+		return false
+	}
+	if jwtMatch.Audience != "" && !audienceContains(claims.GetFields()["aud"], jwtMatch.Audience) {
+		return false
+	}
+	for _, c := range jwtMatch.Claims {
+		if !matchJWTClaim(c, req) {
+			return false
+		}
+	}
+	return true
+}
+
+// audienceContains returns true if aud (the "aud" claim, which per RFC
+// 7519 may be a single string or an array of strings) contains audience.
+func audienceContains(aud *structpb.Value, audience string) bool {
+	if aud == nil {
+		return false
+	}
+	if aud.GetStringValue() == audience {
+		return true
+	}
+	return claimListContains(aud, audience)
+}
+
+func matchJWTClaim(cm *proto.JWTMatch_ClaimMatch, req *requestCache) bool {
+	value, ok := req.resolveClaimPath(cm.Path)
+	if !ok {
+		return false
+	}
+	switch m := cm.Match.(type) {
+	case *proto.JWTMatch_ClaimMatch_Exact:
+		return claimString(value) == m.Exact
+	case *proto.JWTMatch_ClaimMatch_Regex:
+		re, err := compiledRegex(req.store, m.Regex)
+		if err != nil {
+			// Should have been rejected by ValidateJWTMatch at update
+			// time; treat as a data-plane bug rather than silently
+			// failing open or closed.
+			panic(&InvalidDataFromDataPlane{Msg: fmt.Sprintf("invalid JWT claim regex %q: %v", m.Regex, err)})
+		}
+		return re.MatchString(claimString(value))
+	case *proto.JWTMatch_ClaimMatch_In:
+		for _, want := range m.In.GetValues() {
+			if claimString(value) == want {
+				return true
+			}
+		}
+		return false
+	case *proto.JWTMatch_ClaimMatch_Contains:
+		return claimListContains(value, m.Contains)
+	}
+	return false
+}
+
+// claimString renders a scalar claim value as a string for Exact/Regex/In
+// comparisons. Numbers and booleans are formatted the way they'd appear in
+// the JWT's JSON, since claim comparisons are always against string
+// predicates.
+func claimString(v *structpb.Value) string {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return k.StringValue
+	case *structpb.Value_NumberValue:
+		return strconv.FormatFloat(k.NumberValue, 'g', -1, 64)
+	case *structpb.Value_BoolValue:
+		return strconv.FormatBool(k.BoolValue)
+	default:
+		return ""
+	}
+}
+
+// claimListContains returns true if v is an array-valued claim containing
+// target as one of its (scalar) elements.
+func claimListContains(v *structpb.Value, target string) bool {
+	for _, elem := range v.GetListValue().GetValues() {
+		if claimString(elem) == target {
+			return true
+		}
+	}
+	return false
+}
+
+// walkClaimPath walks path (a dot-separated JSON path) through claims,
+// e.g. "realm_access.roles" looks up claims["realm_access"]["roles"].
+func walkClaimPath(claims *structpb.Struct, path string) (*structpb.Value, bool) {
+	if claims == nil || path == "" {
+		return nil, false
+	}
+	fields := claims.GetFields()
+	parts := strings.Split(path, ".")
+	var cur *structpb.Value
+	for i, p := range parts {
+		v, ok := fields[p]
+		if !ok {
+			return nil, false
+		}
+		cur = v
+		if i == len(parts)-1 {
+			break
+		}
+		fields = v.GetStructValue().GetFields()
+		if fields == nil {
+			return nil, false
+		}
+	}
+	return cur, true
+}
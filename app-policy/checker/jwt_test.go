@@ -0,0 +1,133 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/projectcalico/calico/app-policy/checker/mocks"
+	"github.com/projectcalico/calico/app-policy/policystore"
+	"github.com/projectcalico/calico/felix/proto"
+)
+
+func claimsStruct(t *testing.T, fields map[string]interface{}) *structpb.Struct {
+	t.Helper()
+	s, err := structpb.NewStruct(fields)
+	if err != nil {
+		t.Fatalf("building claims struct: %v", err)
+	}
+	return s
+}
+
+func TestMatchJWT(t *testing.T) {
+	claims := claimsStruct(t, map[string]interface{}{
+		"iss": "https://issuer.example.com",
+		"aud": []interface{}{"billing-api", "shipping-api"},
+		"sub": "user-123",
+		"realm_access": map[string]interface{}{
+			"roles": []interface{}{"user", "admin"},
+		},
+		"exp": float64(1700000000),
+	})
+
+	testCases := []struct {
+		title    string
+		jwtMatch *proto.JWTMatch
+		result   bool
+	}{
+		{"nil match", nil, true},
+		{"matching issuer", &proto.JWTMatch{Issuer: "https://issuer.example.com"}, true},
+		{"wrong issuer", &proto.JWTMatch{Issuer: "https://evil.example.com"}, false},
+		{"matching audience in array", &proto.JWTMatch{Audience: "shipping-api"}, true},
+		{"audience not present", &proto.JWTMatch{Audience: "payments-api"}, false},
+		{"exact claim match", &proto.JWTMatch{Claims: []*proto.JWTMatch_ClaimMatch{
+			{Path: "sub", Match: &proto.JWTMatch_ClaimMatch_Exact{Exact: "user-123"}},
+		}}, true},
+		{"exact claim mismatch", &proto.JWTMatch{Claims: []*proto.JWTMatch_ClaimMatch{
+			{Path: "sub", Match: &proto.JWTMatch_ClaimMatch_Exact{Exact: "user-456"}},
+		}}, false},
+		{"regex claim match", &proto.JWTMatch{Claims: []*proto.JWTMatch_ClaimMatch{
+			{Path: "sub", Match: &proto.JWTMatch_ClaimMatch_Regex{Regex: `^user-\d+$`}},
+		}}, true},
+		{"in claim match", &proto.JWTMatch{Claims: []*proto.JWTMatch_ClaimMatch{
+			{Path: "sub", Match: &proto.JWTMatch_ClaimMatch_In{In: &proto.JWTMatch_StringList{Values: []string{"user-999", "user-123"}}}},
+		}}, true},
+		{"in claim no match", &proto.JWTMatch{Claims: []*proto.JWTMatch_ClaimMatch{
+			{Path: "sub", Match: &proto.JWTMatch_ClaimMatch_In{In: &proto.JWTMatch_StringList{Values: []string{"user-999"}}}},
+		}}, false},
+		{"contains claim in nested array", &proto.JWTMatch{Claims: []*proto.JWTMatch_ClaimMatch{
+			{Path: "realm_access.roles", Match: &proto.JWTMatch_ClaimMatch_Contains{Contains: "admin"}},
+		}}, true},
+		{"contains claim missing", &proto.JWTMatch{Claims: []*proto.JWTMatch_ClaimMatch{
+			{Path: "realm_access.roles", Match: &proto.JWTMatch_ClaimMatch_Contains{Contains: "superadmin"}},
+		}}, false},
+		{"numeric claim exact", &proto.JWTMatch{Claims: []*proto.JWTMatch_ClaimMatch{
+			{Path: "exp", Match: &proto.JWTMatch_ClaimMatch_Exact{Exact: "1.7e+09"}},
+		}}, true},
+		{"missing claim path", &proto.JWTMatch{Claims: []*proto.JWTMatch_ClaimMatch{
+			{Path: "nope.nested", Match: &proto.JWTMatch_ClaimMatch_Exact{Exact: "anything"}},
+		}}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			RegisterTestingT(t)
+			flow := &flowWithClaims{claims: claims}
+			req := NewRequestCache(policystore.NewPolicyStore(), flow)
+			Expect(matchJWT(tc.jwtMatch, req)).To(Equal(tc.result))
+		})
+	}
+}
+
+func TestMatchJWTNoVerifiedToken(t *testing.T) {
+	RegisterTestingT(t)
+	flow := &flowWithClaims{claims: nil}
+	req := NewRequestCache(policystore.NewPolicyStore(), flow)
+	Expect(matchJWT(&proto.JWTMatch{Issuer: "https://issuer.example.com"}, req)).To(BeFalse())
+}
+
+func TestValidateJWTMatch(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(ValidateJWTMatch(nil, nil)).To(Succeed())
+	Expect(ValidateJWTMatch(nil, &proto.JWTMatch{Claims: []*proto.JWTMatch_ClaimMatch{
+		{Path: "realm_access.roles", Match: &proto.JWTMatch_ClaimMatch_Regex{Regex: `^adm.*$`}},
+	}})).To(Succeed())
+
+	Expect(ValidateJWTMatch(nil, &proto.JWTMatch{Claims: []*proto.JWTMatch_ClaimMatch{
+		{Path: "", Match: &proto.JWTMatch_ClaimMatch_Exact{Exact: "x"}},
+	}})).ToNot(Succeed())
+
+	Expect(ValidateJWTMatch(nil, &proto.JWTMatch{Claims: []*proto.JWTMatch_ClaimMatch{
+		{Path: "a..b", Match: &proto.JWTMatch_ClaimMatch_Exact{Exact: "x"}},
+	}})).ToNot(Succeed())
+
+	Expect(ValidateJWTMatch(nil, &proto.JWTMatch{Claims: []*proto.JWTMatch_ClaimMatch{
+		{Path: "sub", Match: &proto.JWTMatch_ClaimMatch_Regex{Regex: `(unterminated`}},
+	}})).ToNot(Succeed())
+}
+
+// flowWithClaims is a Flow stub used to exercise JWT matching in
+// isolation; it overrides only GetJWTClaims, since that's the only Flow
+// method matchJWT touches.
+type flowWithClaims struct {
+	mocks.Flow
+	claims *structpb.Struct
+}
+
+func (f *flowWithClaims) GetJWTClaims() *structpb.Struct { return f.claims }
@@ -0,0 +1,537 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package checker evaluates Felix policy rules against Envoy ext_authz
+// CheckRequests. The functions in this file are the leaves of that
+// evaluation: each one matches a single clause of a proto.Rule against the
+// flow under consideration.
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/projectcalico/calico/app-policy/policystore"
+	"github.com/projectcalico/calico/felix/proto"
+	"github.com/projectcalico/calico/felix/types"
+	"github.com/projectcalico/calico/libcalico-go/lib/selector"
+)
+
+// InvalidDataFromDataPlane is panicked when the data received from Felix
+// doesn't match the invariants the checker relies on (e.g. an HTTP path
+// that doesn't start with "/"). Felix is trusted to send well-formed data,
+// so this indicates a bug rather than something callers should try to
+// recover from in the datapath; it is caught and turned into a fail-closed
+// response higher up the call stack.
+type InvalidDataFromDataPlane struct {
+	Msg string
+}
+
+func (e *InvalidDataFromDataPlane) Error() string { return e.Msg }
+
+// match returns true if rule matches the flow held in req. policyNamespace
+// is the namespace of the policy the rule belongs to; it is used to scope
+// pod/service-account selectors to same-namespace peers, matching Felix's
+// enforcement semantics. ctx carries the tracing span for this evaluation,
+// started by the caller (normally CheckRule); match itself only opens child
+// spans around the subphases that are expensive enough to be worth seeing
+// on their own in a trace.
+func match(ctx context.Context, policyNamespace string, rule *proto.Rule, req *requestCache) bool {
+	return matchSrcServiceAccountMatch(rule, req) &&
+		matchDstServiceAccountMatch(rule, req) &&
+		matchPolicyNamespace(policyNamespace, rule, req) &&
+		traceBool(ctx, req, "checker.ipset_match", func() bool {
+			return matchSrcIPSetIds(rule, req) &&
+				matchNotSrcIPSetIds(rule, req) &&
+				matchDstIPSetIds(rule, req) &&
+				matchNotDstIPSetIds(rule, req) &&
+				matchDstIPPortSetIds(rule, req) &&
+				matchNotDstIPPortSetIds(rule, req)
+		}) &&
+		traceBool(ctx, req, "checker.http_match", func() bool {
+			return matchHTTP(rule.HttpMatch, req.GetHTTPMethod(), req.GetHTTPPath(), req.GetHTTPHeaders(), req.GetHTTPHost(), req.store)
+		}) &&
+		matchJWT(rule.JwtMatch, req) &&
+		matchL4Protocol(rule, req.GetProtocol()) &&
+		matchPorts(rule.SrcPorts, req.GetSourcePort()) &&
+		matchPorts(rule.DstPorts, req.GetDestPort()) &&
+		matchSrcNet(rule, req) &&
+		matchDstNet(rule, req) &&
+		traceBool(ctx, req, "checker.namespace_selector_match", func() bool {
+			return matchNamespaceSelector(rule.OriginalSrcNamespaceSelector, principalToNamespace(req.GetSourcePrincipal()), req.store) &&
+				matchNamespaceSelector(rule.OriginalDstNamespaceSelector, principalToNamespace(req.GetDestPrincipal()), req.store)
+		})
+}
+
+// matchName returns true if names is empty (matches any name) or name is
+// one of names.
+func matchName(names []string, name string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLabels returns true if sel is empty (matches any labels) or sel
+// evaluates to true against labels. An unparsable selector never matches;
+// Felix is expected to reject such selectors before they reach us, so this
+// is a defensive fail-closed default rather than the primary validation
+// path.
+func matchLabels(sel string, labels map[string]string) bool {
+	if sel == "" {
+		return true
+	}
+	parsed, err := selector.Parse(sel)
+	if err != nil {
+		return false
+	}
+	return parsed.Evaluate(labels)
+}
+
+// matchHTTPMethods returns true if methods is empty (matches any method),
+// contains "*", or contains an exact (case-sensitive) match for method.
+func matchHTTPMethods(methods []string, method *string) bool {
+	if len(methods) == 0 {
+		return true
+	}
+	if method == nil {
+		return false
+	}
+	for _, m := range methods {
+		if m == "*" || m == *method {
+			return true
+		}
+	}
+	return false
+}
+
+// compiledRegex compiles pattern, or returns the already-compiled regex
+// from store's cache. The cache lives on the PolicyStore (see
+// PolicyStore.CompiledRegex) rather than as a package global, so it never
+// outlives the policy generation it was compiled for: PolicyStore.Bump
+// drops it on every update instead of growing without bound over the life
+// of a long-running process as policies churn. store may be nil (e.g. a
+// unit test matching without a store), in which case pattern is compiled
+// but not cached anywhere.
+func compiledRegex(store *policystore.PolicyStore, pattern string) (*regexp.Regexp, error) {
+	if store == nil {
+		return regexp.Compile(pattern)
+	}
+	return store.CompiledRegex(pattern)
+}
+
+// ValidateHTTPMatch checks that every regex in m compiles, and warms
+// store's compiled-regex cache (see compiledRegex) so that CheckRequest
+// processing never has to compile a regex on the hot path. It should be
+// called with the store a policy update is being applied to, so that a bad
+// regex is rejected as an update error rather than surfacing as an
+// InvalidDataFromDataPlane panic during matching.
+func ValidateHTTPMatch(store *policystore.PolicyStore, m *proto.HTTPMatch) error {
+	if m == nil {
+		return nil
+	}
+	for _, p := range m.Paths {
+		if pattern := p.GetRegex(); pattern != "" {
+			if _, err := compiledRegex(store, pattern); err != nil {
+				return fmt.Errorf("invalid HTTP path regex %q: %w", pattern, err)
+			}
+		}
+	}
+	for _, h := range append(append([]*proto.HTTPMatch_HeaderMatch{}, m.Headers...), m.Hosts...) {
+		if pattern := h.GetRegex(); pattern != "" {
+			if _, err := compiledRegex(store, pattern); err != nil {
+				return fmt.Errorf("invalid HTTP header/host regex for %q: %q: %w", h.Name, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// matchHTTPPaths returns true if paths is empty (matches any path), or
+// reqPath matches at least one of paths under its Exact/Prefix/Regex rule.
+// reqPath is matched with any query string or fragment stripped, exactly
+// as Envoy presents ":path" for HTTP/1.1 and HTTP/2 requests.
+func matchHTTPPaths(paths []*proto.HTTPMatch_PathMatch, reqPath *string, store *policystore.PolicyStore) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	if reqPath == nil {
+		return false
+	}
+	path := stripQueryAndFragment(*reqPath)
+	if !strings.HasPrefix(path, "/") {
+		panic(&InvalidDataFromDataPlane{Msg: fmt.Sprintf("HTTP path %q does not start with '/'", *reqPath)})
+	}
+	for _, p := range paths {
+		switch m := p.PathMatch.(type) {
+		case *proto.HTTPMatch_PathMatch_Exact:
+			if path == m.Exact {
+				return true
+			}
+		case *proto.HTTPMatch_PathMatch_Prefix:
+			if strings.HasPrefix(path, m.Prefix) {
+				return true
+			}
+		case *proto.HTTPMatch_PathMatch_Regex:
+			re, err := compiledRegex(store, m.Regex)
+			if err != nil {
+				// Should have been rejected by ValidateHTTPMatch at update
+				// time; treat as a data-plane bug rather than silently
+				// failing open or closed.
+				panic(&InvalidDataFromDataPlane{Msg: fmt.Sprintf("invalid HTTP path regex %q: %v", m.Regex, err)})
+			}
+			if re.MatchString(path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func stripQueryAndFragment(path string) string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// matchHTTPHeaderClause returns true if a single header-match entry is
+// satisfied by value, which was found in the request if present is true.
+func matchHTTPHeaderClause(hm *proto.HTTPMatch_HeaderMatch, value string, present bool, store *policystore.PolicyStore) bool {
+	switch m := hm.Match.(type) {
+	case *proto.HTTPMatch_HeaderMatch_Exact:
+		return present && value == m.Exact
+	case *proto.HTTPMatch_HeaderMatch_Prefix:
+		return present && strings.HasPrefix(value, m.Prefix)
+	case *proto.HTTPMatch_HeaderMatch_Regex:
+		re, err := compiledRegex(store, m.Regex)
+		if err != nil {
+			// Should have been rejected by ValidateHTTPMatch at update
+			// time; treat as a data-plane bug rather than silently
+			// failing open or closed.
+			panic(&InvalidDataFromDataPlane{Msg: fmt.Sprintf("invalid header regex %q: %v", m.Regex, err)})
+		}
+		return present && re.MatchString(value)
+	case *proto.HTTPMatch_HeaderMatch_Present:
+		return present
+	case *proto.HTTPMatch_HeaderMatch_NotPresent:
+		return !present
+	}
+	return false
+}
+
+// matchHTTPHeaders returns true if headerMatches is empty (matches any
+// headers), or every entry is satisfied. Header names are looked up
+// case-insensitively (as http.Header.Values does); values are compared
+// case-sensitively.
+func matchHTTPHeaders(headerMatches []*proto.HTTPMatch_HeaderMatch, headers http.Header, store *policystore.PolicyStore) bool {
+	for _, hm := range headerMatches {
+		vals := headers.Values(hm.Name)
+		value := ""
+		if len(vals) > 0 {
+			value = vals[0]
+		}
+		if !matchHTTPHeaderClause(hm, value, len(vals) > 0, store) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchHTTPHosts returns true if hostMatches is empty (matches any host),
+// or every entry is satisfied by the request's effective Host/:authority
+// value.
+func matchHTTPHosts(hostMatches []*proto.HTTPMatch_HeaderMatch, host string, store *policystore.PolicyStore) bool {
+	for _, hm := range hostMatches {
+		if !matchHTTPHeaderClause(hm, host, host != "", store) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchHTTP returns true if httpMatch is nil (an omitted HTTP Match clause
+// always matches), or method, path, headers and host all satisfy
+// httpMatch's clauses. store scopes the compiled-regex cache used for any
+// Regex clauses (see compiledRegex); it may be nil.
+func matchHTTP(httpMatch *proto.HTTPMatch, method *string, path *string, headers http.Header, host string, store *policystore.PolicyStore) bool {
+	if httpMatch == nil {
+		return true
+	}
+	return matchHTTPMethods(httpMatch.Methods, method) &&
+		matchHTTPPaths(httpMatch.Paths, path, store) &&
+		matchHTTPHeaders(httpMatch.Headers, headers, store) &&
+		matchHTTPHosts(httpMatch.Hosts, host, store)
+}
+
+var protocolNumbersByName = map[string]int{
+	"ICMP":    1,
+	"TCP":     6,
+	"UDP":     17,
+	"ICMPv6":  58,
+	"SCTP":    132,
+	"UDPLite": 136,
+}
+
+// protocolMatches returns true if p (a rule's Protocol/NotProtocol clause)
+// identifies the same protocol as protoNum.
+func protocolMatches(p *proto.Protocol, protoNum int) bool {
+	if p == nil {
+		return false
+	}
+	if name := p.GetName(); name != "" {
+		num, ok := protocolNumbersByName[name]
+		return ok && num == protoNum
+	}
+	return int(p.GetNumber()) == protoNum
+}
+
+// matchL4Protocol returns true if rule's Protocol/NotProtocol clauses
+// (each independently optional) match protoNum. protoNum must be a valid
+// IP protocol number (the IP header's protocol field is a single byte);
+// anything outside that range is treated as malformed data and never
+// matches.
+func matchL4Protocol(rule *proto.Rule, protoNum int) bool {
+	if protoNum <= 0 || protoNum > 255 {
+		return false
+	}
+	if rule.Protocol != nil && !protocolMatches(rule.Protocol, protoNum) {
+		return false
+	}
+	if rule.NotProtocol != nil && protocolMatches(rule.NotProtocol, protoNum) {
+		return false
+	}
+	return true
+}
+
+// matchPorts returns true if ports is empty (matches any port), or port
+// falls within at least one of the inclusive [First, Last] ranges.
+func matchPorts(ports []*proto.PortRange, port int) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	for _, r := range ports {
+		if port >= int(r.First) && port <= int(r.Last) {
+			return true
+		}
+	}
+	return false
+}
+
+// trieForNets builds (or returns the cached) NetTrie for the distinct
+// SrcNet/DstNet list nets, so that repeated CheckRequests against the same
+// rule don't re-walk the rule's CIDR list linearly. The cache lives on
+// store (see PolicyStore.TrieForNets) rather than as a package global, so a
+// long-running process's cache of tries doesn't grow without bound as
+// policies churn: PolicyStore.Bump drops it on every update. store may be
+// nil (e.g. a unit test matching without a store), in which case the trie
+// is built but not cached anywhere.
+func trieForNets(nets []string, store *policystore.PolicyStore) *policystore.NetTrie {
+	if store != nil {
+		return store.TrieForNets(nets)
+	}
+	t := policystore.NewNetTrie()
+	for i, n := range nets {
+		// A CIDR that fails to parse never matches, but doesn't abort
+		// insertion of the rest of the list.
+		_ = t.Insert(n, strconv.Itoa(i))
+	}
+	return t
+}
+
+// matchNet returns true if nets is empty (matches any address), or ip
+// falls within at least one of nets. label is used only to make log
+// messages about malformed CIDRs identify which clause they came from.
+func matchNet(label string, nets []string, ip net.IP, store *policystore.PolicyStore) bool {
+	if len(nets) == 0 {
+		return true
+	}
+	return len(trieForNets(nets, store).LongestMatch(ip)) > 0
+}
+
+// matchNotNet returns true if notNets is empty (nothing excluded), or ip
+// falls within none of notNets.
+func matchNotNet(label string, notNets []string, ip net.IP, store *policystore.PolicyStore) bool {
+	if len(notNets) == 0 {
+		return true
+	}
+	return len(trieForNets(notNets, store).LongestMatch(ip)) == 0
+}
+
+func matchSrcNet(rule *proto.Rule, req *requestCache) bool {
+	ip := req.GetSourceIP()
+	return matchNet("src", rule.SrcNet, ip, req.store) && matchNotNet("not-src", rule.NotSrcNet, ip, req.store)
+}
+
+func matchDstNet(rule *proto.Rule, req *requestCache) bool {
+	ip := req.GetDestIP()
+	return matchNet("dst", rule.DstNet, ip, req.store) && matchNotNet("not-dst", rule.NotDstNet, ip, req.store)
+}
+
+func matchIPSetIds(ids []string, req *requestCache, addr net.IP) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	if req.store == nil {
+		return false
+	}
+	for _, id := range ids {
+		if s, ok := req.store.IPSetByID[id]; ok && s.ContainsAddress(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchNotIPSetIds(ids []string, req *requestCache, addr net.IP) bool {
+	if req.store == nil {
+		return true
+	}
+	for _, id := range ids {
+		if s, ok := req.store.IPSetByID[id]; ok && s.ContainsAddress(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchSrcIPSetIds(rule *proto.Rule, req *requestCache) bool {
+	return matchIPSetIds(rule.SrcIpSetIds, req, req.GetSourceIP())
+}
+
+func matchNotSrcIPSetIds(rule *proto.Rule, req *requestCache) bool {
+	return matchNotIPSetIds(rule.NotSrcIpSetIds, req, req.GetSourceIP())
+}
+
+func matchDstIPSetIds(rule *proto.Rule, req *requestCache) bool {
+	return matchIPSetIds(rule.DstIpSetIds, req, req.GetDestIP())
+}
+
+func matchNotDstIPSetIds(rule *proto.Rule, req *requestCache) bool {
+	return matchNotIPSetIds(rule.NotDstIpSetIds, req, req.GetDestIP())
+}
+
+func protocolName(protoNum int) string {
+	for name, num := range protocolNumbersByName {
+		if num == protoNum {
+			return strings.ToLower(name)
+		}
+	}
+	return strconv.Itoa(protoNum)
+}
+
+// matchDstIPPortSetIds returns true if rule.DstIpPortSetIds is empty, or
+// the flow's destination address/protocol/port is a member of at least one
+// of the named IP,port sets.
+func matchDstIPPortSetIds(rule *proto.Rule, req *requestCache) bool {
+	if len(rule.DstIpPortSetIds) == 0 {
+		return true
+	}
+	if req.store == nil {
+		return false
+	}
+	protoName := protocolName(req.GetProtocol())
+	for _, id := range rule.DstIpPortSetIds {
+		s, ok := req.store.IPSetByID[id]
+		if !ok {
+			continue
+		}
+		if s.ContainsAddressPort(req.GetDestIP(), protoName, req.GetDestPort()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchNotDstIPPortSetIds returns true if rule.NotDstIpPortSetIds is empty,
+// or the flow's destination address/protocol/port is a member of none of
+// the named IP,port sets.
+func matchNotDstIPPortSetIds(rule *proto.Rule, req *requestCache) bool {
+	if req.store == nil {
+		return true
+	}
+	protoName := protocolName(req.GetProtocol())
+	for _, id := range rule.NotDstIpPortSetIds {
+		s, ok := req.store.IPSetByID[id]
+		if !ok {
+			continue
+		}
+		if s.ContainsAddressPort(req.GetDestIP(), protoName, req.GetDestPort()) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchSrcServiceAccountMatch(rule *proto.Rule, req *requestCache) bool {
+	if rule.SrcServiceAccountMatch == nil {
+		return true
+	}
+	return matchName(rule.SrcServiceAccountMatch.Names, principalToServiceAccount(req.GetSourcePrincipal()))
+}
+
+func matchDstServiceAccountMatch(rule *proto.Rule, req *requestCache) bool {
+	if rule.DstServiceAccountMatch == nil {
+		return true
+	}
+	return matchName(rule.DstServiceAccountMatch.Names, principalToServiceAccount(req.GetDestPrincipal()))
+}
+
+// matchPolicyNamespace enforces that, when a rule scopes its peer by pod
+// selector or service account, the peer must be in the same namespace as
+// the policy itself. Rules with neither clause set (e.g. IP/CIDR-only
+// rules) are allowed to match peers in any namespace.
+func matchPolicyNamespace(policyNamespace string, rule *proto.Rule, req *requestCache) bool {
+	return matchPeerNamespace(policyNamespace, rule.OriginalSrcSelector, rule.SrcServiceAccountMatch, principalToNamespace(req.GetSourcePrincipal())) &&
+		matchPeerNamespace(policyNamespace, rule.OriginalDstSelector, rule.DstServiceAccountMatch, principalToNamespace(req.GetDestPrincipal()))
+}
+
+func matchPeerNamespace(policyNamespace, podSelector string, saMatch *proto.ServiceAccountMatch, peerNamespace string) bool {
+	// Policies with no namespace (e.g. GlobalNetworkPolicy) aren't scoped
+	// to a particular namespace, so they never enforce this same-namespace
+	// restriction.
+	if policyNamespace == "" {
+		return true
+	}
+	if podSelector == "" && saMatch == nil {
+		return true
+	}
+	return peerNamespace == policyNamespace
+}
+
+// matchNamespaceSelector returns true if sel is empty (matches any
+// namespace), or the named namespace is known and its labels satisfy sel.
+func matchNamespaceSelector(sel string, namespaceName string, store *policystore.PolicyStore) bool {
+	if sel == "" {
+		return true
+	}
+	if store == nil {
+		return false
+	}
+	ns, ok := store.NamespaceByID[types.NamespaceID{Name: namespaceName}]
+	if !ok {
+		return false
+	}
+	return matchLabels(sel, ns.Labels)
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/projectcalico/calico/app-policy/policystore"
+)
+
+// netsOfSize returns n distinct /32 CIDRs plus one /16 that the benchmark
+// IP always falls within, so LongestMatch always has to walk to a leaf
+// rather than short-circuiting on the first bit.
+func netsOfSize(n int) []string {
+	nets := make([]string, 0, n+1)
+	nets = append(nets, "10.0.0.0/16")
+	for i := 0; i < n; i++ {
+		nets = append(nets, fmt.Sprintf("172.%d.%d.%d/32", (i>>16)&0xff, (i>>8)&0xff, i&0xff))
+	}
+	return nets
+}
+
+func benchmarkMatchNet(b *testing.B, n int) {
+	nets := netsOfSize(n)
+	ip := net.ParseIP("10.0.1.2")
+	// A store, so repeated calls hit PolicyStore.TrieForNets' cache instead
+	// of rebuilding the trie every iteration: that's what this benchmark is
+	// meant to measure the cost of.
+	store := policystore.NewPolicyStore()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matchNet("dst", nets, ip, store)
+	}
+}
+
+func BenchmarkMatchNet10kPrefixes(b *testing.B) {
+	benchmarkMatchNet(b, 10000)
+}
+
+func BenchmarkMatchNet100kPrefixes(b *testing.B) {
+	benchmarkMatchNet(b, 100000)
+}
@@ -15,11 +15,14 @@
 package checker
 
 import (
+	"context"
+	"net/http"
 	"testing"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	auth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
 	. "github.com/onsi/gomega"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/projectcalico/calico/app-policy/checker/mocks"
 	"github.com/projectcalico/calico/app-policy/policystore"
@@ -132,12 +135,21 @@ func TestMatchHTTPPaths(t *testing.T) {
 		{"exact path with fragment", []*proto.HTTPMatch_PathMatch{{PathMatch: &proto.HTTPMatch_PathMatch_Exact{Exact: "/foo"}}}, "/foo#xyz", true},
 		{"prefix path with query fail", []*proto.HTTPMatch_PathMatch{{PathMatch: &proto.HTTPMatch_PathMatch_Prefix{Prefix: "/foobar"}}}, "/foo?bar", false},
 		{"prefix path with fragment fail", []*proto.HTTPMatch_PathMatch{{PathMatch: &proto.HTTPMatch_PathMatch_Prefix{Prefix: "/foobar"}}}, "/foo#bar", false},
+		{"regex anchored match", []*proto.HTTPMatch_PathMatch{{PathMatch: &proto.HTTPMatch_PathMatch_Regex{Regex: `^/api/v[0-9]+/users/[^/]+$`}}}, "/api/v2/users/123", true},
+		{"regex anchored no match", []*proto.HTTPMatch_PathMatch{{PathMatch: &proto.HTTPMatch_PathMatch_Regex{Regex: `^/api/v[0-9]+/users/[^/]+$`}}}, "/api/v2/users/123/orders", false},
+		{"regex unanchored match", []*proto.HTTPMatch_PathMatch{{PathMatch: &proto.HTTPMatch_PathMatch_Regex{Regex: `/users/`}}}, "/api/v2/users/123", true},
+		{"regex with query stripped", []*proto.HTTPMatch_PathMatch{{PathMatch: &proto.HTTPMatch_PathMatch_Regex{Regex: `^/foo$`}}}, "/foo?xyz", true},
+		{"regex alongside exact and prefix", []*proto.HTTPMatch_PathMatch{
+			{PathMatch: &proto.HTTPMatch_PathMatch_Exact{Exact: "/exact"}},
+			{PathMatch: &proto.HTTPMatch_PathMatch_Prefix{Prefix: "/pre"}},
+			{PathMatch: &proto.HTTPMatch_PathMatch_Regex{Regex: `^/api/v[0-9]+/?$`}},
+		}, "/api/v10", true},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.title, func(t *testing.T) {
 			RegisterTestingT(t)
-			Expect(matchHTTPPaths(tc.paths, &tc.reqPath)).To(Equal(tc.result))
+			Expect(matchHTTPPaths(tc.paths, &tc.reqPath, nil)).To(Equal(tc.result))
 		})
 	}
 }
@@ -146,7 +158,63 @@ func TestMatchHTTPPaths(t *testing.T) {
 func TestMatchHTTPNil(t *testing.T) {
 	RegisterTestingT(t)
 
-	Expect(matchHTTP(nil, nil, nil)).To(BeTrue())
+	Expect(matchHTTP(nil, nil, nil, nil, "", nil)).To(BeTrue())
+}
+
+// HTTP Headers clause: every listed header must satisfy its own match
+// kind; headers not mentioned are ignored.
+func TestMatchHTTPHeaders(t *testing.T) {
+	testCases := []struct {
+		title   string
+		headers []*proto.HTTPMatch_HeaderMatch
+		reqHdrs http.Header
+		result  bool
+	}{
+		{"empty", nil, http.Header{"X-Foo": {"bar"}}, true},
+		{"exact match", []*proto.HTTPMatch_HeaderMatch{{Name: "X-Foo", Match: &proto.HTTPMatch_HeaderMatch_Exact{Exact: "bar"}}}, http.Header{"X-Foo": {"bar"}}, true},
+		{"exact case-insensitive name", []*proto.HTTPMatch_HeaderMatch{{Name: "x-foo", Match: &proto.HTTPMatch_HeaderMatch_Exact{Exact: "bar"}}}, http.Header{"X-Foo": {"bar"}}, true},
+		{"exact case-sensitive value fail", []*proto.HTTPMatch_HeaderMatch{{Name: "X-Foo", Match: &proto.HTTPMatch_HeaderMatch_Exact{Exact: "Bar"}}}, http.Header{"X-Foo": {"bar"}}, false},
+		{"prefix match", []*proto.HTTPMatch_HeaderMatch{{Name: "X-Foo", Match: &proto.HTTPMatch_HeaderMatch_Prefix{Prefix: "ba"}}}, http.Header{"X-Foo": {"bar"}}, true},
+		{"regex match", []*proto.HTTPMatch_HeaderMatch{{Name: "X-Foo", Match: &proto.HTTPMatch_HeaderMatch_Regex{Regex: "^ba[rz]$"}}}, http.Header{"X-Foo": {"bar"}}, true},
+		{"present", []*proto.HTTPMatch_HeaderMatch{{Name: "X-Foo", Match: &proto.HTTPMatch_HeaderMatch_Present{Present: true}}}, http.Header{"X-Foo": {"bar"}}, true},
+		{"present but missing", []*proto.HTTPMatch_HeaderMatch{{Name: "X-Foo", Match: &proto.HTTPMatch_HeaderMatch_Present{Present: true}}}, http.Header{}, false},
+		{"not present", []*proto.HTTPMatch_HeaderMatch{{Name: "X-Foo", Match: &proto.HTTPMatch_HeaderMatch_NotPresent{NotPresent: true}}}, http.Header{}, true},
+		{"not present but there", []*proto.HTTPMatch_HeaderMatch{{Name: "X-Foo", Match: &proto.HTTPMatch_HeaderMatch_NotPresent{NotPresent: true}}}, http.Header{"X-Foo": {"bar"}}, false},
+		{"multiple headers all must match", []*proto.HTTPMatch_HeaderMatch{
+			{Name: "X-Foo", Match: &proto.HTTPMatch_HeaderMatch_Exact{Exact: "bar"}},
+			{Name: "X-Baz", Match: &proto.HTTPMatch_HeaderMatch_Present{Present: true}},
+		}, http.Header{"X-Foo": {"bar"}}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			RegisterTestingT(t)
+			Expect(matchHTTPHeaders(tc.headers, tc.reqHdrs, nil)).To(Equal(tc.result))
+		})
+	}
+}
+
+// HTTP Hosts clause matches the effective Host/:authority value.
+func TestMatchHTTPHost(t *testing.T) {
+	testCases := []struct {
+		title  string
+		hosts  []*proto.HTTPMatch_HeaderMatch
+		host   string
+		result bool
+	}{
+		{"empty", nil, "example.com", true},
+		{"exact match", []*proto.HTTPMatch_HeaderMatch{{Name: "Host", Match: &proto.HTTPMatch_HeaderMatch_Exact{Exact: "example.com"}}}, "example.com", true},
+		{"exact fail", []*proto.HTTPMatch_HeaderMatch{{Name: "Host", Match: &proto.HTTPMatch_HeaderMatch_Exact{Exact: "example.com"}}}, "example.org", false},
+		{"prefix match", []*proto.HTTPMatch_HeaderMatch{{Name: "Host", Match: &proto.HTTPMatch_HeaderMatch_Prefix{Prefix: "api."}}}, "api.example.com", true},
+		{"regex match", []*proto.HTTPMatch_HeaderMatch{{Name: "Host", Match: &proto.HTTPMatch_HeaderMatch_Regex{Regex: `^[a-z]+\.example\.com$`}}}, "api.example.com", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			RegisterTestingT(t)
+			Expect(matchHTTPHosts(tc.hosts, tc.host, nil)).To(Equal(tc.result))
+		})
+	}
 }
 
 // Test HTTPPaths panic on invalid data.
@@ -158,7 +226,44 @@ func TestPanicHTTPPaths(t *testing.T) {
 	}()
 	paths := []*proto.HTTPMatch_PathMatch{{PathMatch: &proto.HTTPMatch_PathMatch_Exact{Exact: "/foo"}}}
 	reqPath := "foo"
-	matchHTTPPaths(paths, &reqPath)
+	matchHTTPPaths(paths, &reqPath, nil)
+}
+
+// ValidateHTTPMatch should accept valid regexes and reject invalid ones
+// with a plain error, rather than panicking, so that a bad rule is
+// rejected as a policy update error instead of at CheckRequest time.
+func TestValidateHTTPMatchRegex(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(ValidateHTTPMatch(nil, nil)).To(BeNil())
+
+	valid := &proto.HTTPMatch{
+		Paths: []*proto.HTTPMatch_PathMatch{
+			{PathMatch: &proto.HTTPMatch_PathMatch_Regex{Regex: `^/api/v[0-9]+/users/[^/]+$`}},
+		},
+	}
+	Expect(ValidateHTTPMatch(nil, valid)).To(BeNil())
+
+	invalid := &proto.HTTPMatch{
+		Paths: []*proto.HTTPMatch_PathMatch{
+			{PathMatch: &proto.HTTPMatch_PathMatch_Regex{Regex: `/api/v[0-9+/users`}},
+		},
+	}
+	Expect(ValidateHTTPMatch(nil, invalid)).ToNot(BeNil())
+}
+
+// An invalid regex that somehow reaches matchHTTPPaths unvalidated (e.g. a
+// bug in the update path) must fail closed via a panic like the other
+// InvalidDataFromDataPlane cases, not silently match or not-match.
+func TestPanicHTTPPathsInvalidRegex(t *testing.T) {
+	RegisterTestingT(t)
+
+	defer func() {
+		Expect(recover()).To(BeAssignableToTypeOf(&InvalidDataFromDataPlane{}))
+	}()
+	paths := []*proto.HTTPMatch_PathMatch{{PathMatch: &proto.HTTPMatch_PathMatch_Regex{Regex: `/api/v[0-9+/users`}}}
+	reqPath := "/api/v1/users"
+	matchHTTPPaths(paths, &reqPath, nil)
 }
 
 // Matching a whole rule should require matching all subclauses.
@@ -198,7 +303,23 @@ func TestMatchRule(t *testing.T) {
 		},
 		SrcNet: []string{"192.168.4.0/24"},
 		DstNet: []string{"10.54.0.0/16"},
+
+		JwtMatch: &proto.JWTMatch{
+			Issuer:   "https://issuer.example.com",
+			Audience: "billing-api",
+			Claims: []*proto.JWTMatch_ClaimMatch{
+				{Path: "realm_access.roles", Match: &proto.JWTMatch_ClaimMatch_Contains{Contains: "admin"}},
+			},
+		},
 	}
+	jwtClaims, err := structpb.NewStruct(map[string]interface{}{
+		"iss": "https://issuer.example.com",
+		"aud": "billing-api",
+		"realm_access": map[string]interface{}{
+			"roles": []interface{}{"user", "admin"},
+		},
+	})
+	Expect(err).NotTo(HaveOccurred())
 	req := &auth.CheckRequest{Attributes: &auth.AttributeContext{
 		Source: &auth.AttributeContext_Peer{
 			Principal: "spiffe://cluster.local/ns/default/sa/sam",
@@ -224,6 +345,11 @@ func TestMatchRule(t *testing.T) {
 				Path:   "/path",
 			},
 		},
+		MetadataContext: &core.Metadata{
+			FilterMetadata: map[string]*structpb.Struct{
+				jwtAuthnMetadataKey: jwtClaims,
+			},
+		},
 	}}
 
 	store := policystore.NewPolicyStore()
@@ -238,98 +364,121 @@ func TestMatchRule(t *testing.T) {
 
 	flow := NewCheckRequestToFlowAdapter(req)
 	reqCache := NewRequestCache(store, flow)
-	Expect(match("", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
 
 	// SrcServiceAccountMatch
 	ossan := rule.SrcServiceAccountMatch.Names
 	rule.SrcServiceAccountMatch.Names = []string{"wendy"}
-	Expect(match("", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
 	rule.SrcServiceAccountMatch.Names = ossan
-	Expect(match("", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
 
 	// DstServiceAccountMatch
 	odsan := rule.DstServiceAccountMatch.Names
 	rule.DstServiceAccountMatch.Names = []string{"wendy"}
-	Expect(match("", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
 	rule.DstServiceAccountMatch.Names = odsan
-	Expect(match("", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
 
 	// SrcIpSetIds
 	osipi := rule.SrcIpSetIds
 	rule.SrcIpSetIds = []string{"notSrc0"}
-	Expect(match("", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
 	rule.SrcIpSetIds = osipi
-	Expect(match("", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
 
 	// DstIpSetIds
 	odipi := rule.DstIpSetIds
 	rule.DstIpSetIds = []string{"notDst0"}
-	Expect(match("", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
 	rule.DstIpSetIds = odipi
-	Expect(match("", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
 
 	// NotSrcIpSetIds
 	onsipi := rule.NotSrcIpSetIds
 	rule.NotSrcIpSetIds = []string{"src0"}
-	Expect(match("", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
 	rule.NotSrcIpSetIds = onsipi
-	Expect(match("", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
 
 	// NotDstIpSetIds
 	ondipi := rule.NotDstIpSetIds
 	rule.NotDstIpSetIds = []string{"dst0"}
-	Expect(match("", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
 	rule.NotDstIpSetIds = ondipi
-	Expect(match("", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
 
 	// HTTPMatch
 	ohm := rule.HttpMatch.Methods
 	rule.HttpMatch.Methods = []string{"HEAD"}
-	Expect(match("", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
 	rule.HttpMatch.Methods = ohm
-	Expect(match("", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
 
 	// HTTPPath
 	ohp := rule.HttpMatch.Paths
 	rule.HttpMatch.Paths = []*proto.HTTPMatch_PathMatch{{PathMatch: &proto.HTTPMatch_PathMatch_Exact{Exact: "/nopath"}}}
-	Expect(match("", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
 	rule.HttpMatch.Paths = ohp
-	Expect(match("", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
 
 	// Protocol
 	op := rule.Protocol.GetName()
 	rule.Protocol.NumberOrName = &proto.Protocol_Name{Name: "UDP"}
-	Expect(match("", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
 	rule.Protocol.NumberOrName = &proto.Protocol_Name{Name: op}
-	Expect(match("", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
 
 	// SrcPorts
 	osp := rule.SrcPorts
 	rule.SrcPorts = []*proto.PortRange{{First: 25, Last: 25}}
-	Expect(match("", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
 	rule.SrcPorts = osp
-	Expect(match("", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
 
 	// DstPorts
 	odp := rule.DstPorts
 	rule.DstPorts = []*proto.PortRange{{First: 25, Last: 25}}
-	Expect(match("", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
 	rule.DstPorts = odp
-	Expect(match("", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
 
 	// SrcNet
 	osn := rule.SrcNet
 	rule.SrcNet = []string{"30.0.0.0/8"}
-	Expect(match("", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
 	rule.SrcNet = osn
-	Expect(match("", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
 
 	// DstNet
 	odn := rule.DstNet
 	rule.DstNet = []string{"30.0.0.0/8"}
-	Expect(match("", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
 	rule.DstNet = odn
-	Expect(match("", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
+
+	// JwtMatch: issuer
+	oiss := rule.JwtMatch.Issuer
+	rule.JwtMatch.Issuer = "https://wrong-issuer.example.com"
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
+	rule.JwtMatch.Issuer = oiss
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
+
+	// JwtMatch: audience
+	oaud := rule.JwtMatch.Audience
+	rule.JwtMatch.Audience = "other-api"
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
+	rule.JwtMatch.Audience = oaud
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
+
+	// JwtMatch: claims
+	ojc := rule.JwtMatch.Claims
+	rule.JwtMatch.Claims = []*proto.JWTMatch_ClaimMatch{
+		{Path: "realm_access.roles", Match: &proto.JWTMatch_ClaimMatch_Contains{Contains: "superadmin"}},
+	}
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeFalse())
+	rule.JwtMatch.Claims = ojc
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
 }
 
 // Test namespace selectors are handled correctly
@@ -361,7 +510,7 @@ func TestMatchRuleNamespaceSelectors(t *testing.T) {
 	id = proto.NamespaceID{Name: "dst"}
 	store.NamespaceByID[types.ProtoToNamespaceID(&id)] = &proto.NamespaceUpdate{Id: &id, Labels: map[string]string{"place": "dst"}}
 	reqCache := NewRequestCache(store, flow)
-	Expect(match("", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "", rule, reqCache)).To(BeTrue())
 }
 
 // Test that rules only match same namespace if pod selector or service account is set
@@ -390,17 +539,17 @@ func TestMatchRulePolicyNamespace(t *testing.T) {
 	rule := &proto.Rule{
 		OriginalSrcSelector: "has(app)",
 	}
-	Expect(match("different", rule, reqCache)).To(BeFalse())
-	Expect(match("testns", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "different", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "testns", rule, reqCache)).To(BeTrue())
 
 	// With no pod selector or SA selector
 	rule.OriginalSrcSelector = ""
-	Expect(match("different", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "different", rule, reqCache)).To(BeTrue())
 
 	// With SA selector
 	rule.SrcServiceAccountMatch = &proto.ServiceAccountMatch{Names: []string{"sam"}}
-	Expect(match("different", rule, reqCache)).To(BeFalse())
-	Expect(match("testns", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "different", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "testns", rule, reqCache)).To(BeTrue())
 }
 
 func addIPSet(store *policystore.PolicyStore, id string, addr ...string) {
@@ -435,11 +584,11 @@ func TestMatchL4Protocol(t *testing.T) {
 
 	// With empty rule and default request.
 	rule := &proto.Rule{}
-	Expect(match("testns", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "testns", rule, reqCache)).To(BeTrue())
 
 	// With empty rule and UDP request
 	req.GetAttributes().GetDestination().Address = socketAddressProtocolUDP
-	Expect(match("testns", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "testns", rule, reqCache)).To(BeTrue())
 	req.GetAttributes().GetDestination().Address = nil
 
 	// With Protocol=TCP rule and default request
@@ -448,7 +597,7 @@ func TestMatchL4Protocol(t *testing.T) {
 			Name: "TCP",
 		},
 	}
-	Expect(match("testns", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "testns", rule, reqCache)).To(BeTrue())
 	rule.Protocol = nil
 
 	// With Protocol=6 rule and default request
@@ -457,7 +606,7 @@ func TestMatchL4Protocol(t *testing.T) {
 			Number: 6,
 		},
 	}
-	Expect(match("testns", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "testns", rule, reqCache)).To(BeTrue())
 	rule.Protocol = nil
 
 	// With Protocol=17 rule and default request
@@ -466,7 +615,7 @@ func TestMatchL4Protocol(t *testing.T) {
 			Number: 17,
 		},
 	}
-	Expect(match("testns", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "testns", rule, reqCache)).To(BeFalse())
 	rule.Protocol = nil
 
 	// With Protocol!=UDP rule and default request
@@ -475,7 +624,7 @@ func TestMatchL4Protocol(t *testing.T) {
 			Name: "UDP",
 		},
 	}
-	Expect(match("testns", rule, reqCache)).To(BeTrue())
+	Expect(match(context.Background(), "testns", rule, reqCache)).To(BeTrue())
 	rule.NotProtocol = nil
 
 	// With Protocol!=6 rule and TCP request
@@ -485,7 +634,7 @@ func TestMatchL4Protocol(t *testing.T) {
 		},
 	}
 	req.GetAttributes().GetDestination().Address = socketAddressProtocolTCP
-	Expect(match("testns", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "testns", rule, reqCache)).To(BeFalse())
 	req.GetAttributes().GetDestination().Address = nil
 	rule.NotProtocol = nil
 
@@ -501,7 +650,7 @@ func TestMatchL4Protocol(t *testing.T) {
 		},
 	}
 	req.GetAttributes().GetDestination().Address = socketAddressProtocolTCP
-	Expect(match("testns", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "testns", rule, reqCache)).To(BeFalse())
 	req.GetAttributes().GetDestination().Address = nil
 	rule.NotProtocol = nil
 
@@ -517,7 +666,7 @@ func TestMatchL4Protocol(t *testing.T) {
 		},
 	}
 	req.GetAttributes().GetDestination().Address = socketAddressProtocolUDP
-	Expect(match("testns", rule, reqCache)).To(BeFalse())
+	Expect(match(context.Background(), "testns", rule, reqCache)).To(BeFalse())
 	req.GetAttributes().GetDestination().Address = nil
 	rule.NotProtocol = nil
 
@@ -806,7 +955,7 @@ func TestMatchNet(t *testing.T) {
 			RegisterTestingT(t)
 
 			ip := libnet.ParseIP(tc.ip)
-			Expect(matchNet("test", tc.nets, ip.Network().IP)).To(Equal(tc.match))
+			Expect(matchNet("test", tc.nets, ip.Network().IP, nil)).To(Equal(tc.match))
 		})
 	}
 }
@@ -816,7 +965,7 @@ func TestMatchNetBadCIDR(t *testing.T) {
 
 	ip := libnet.ParseIP("192.168.5.6")
 	nets := []string{"192.168.0.0.0/16"}
-	Expect(matchNet("test", nets, ip.Network().IP)).To(BeFalse())
+	Expect(matchNet("test", nets, ip.Network().IP, nil)).To(BeFalse())
 }
 
 func TestMatchNets(t *testing.T) {
@@ -836,6 +985,9 @@ func TestMatchNets(t *testing.T) {
 		{"multiple nets match", []string{"192.168.2.0/24", "192.168.1.0/24"}, "192.168.1.1", "192.168.1.1", true, true},
 		{"multiple nets no match", []string{"192.168.2.0/24", "192.168.3.0/24"}, "192.168.1.1", "192.168.1.1", false, false},
 		{"invalid net", []string{"invalid"}, "192.168.1.1", "192.168.1.1", false, false},
+		{"single v6 net match", []string{"2001:db8::/32"}, "2001:db8::1", "2001:db8::1", true, true},
+		{"single v6 net no match", []string{"2001:db9::/32"}, "2001:db8::1", "2001:db8::1", false, false},
+		{"mixed v4/v6 nets match", []string{"192.168.1.0/24", "2001:db8::/32"}, "2001:db8::1", "192.168.1.1", true, true},
 	}
 
 	for _, tc := range testCases {
@@ -849,8 +1001,8 @@ func TestMatchNets(t *testing.T) {
 			dstFlow := &mocks.Flow{}
 			dstFlow.On("GetDestIP").Return(dstIP)
 
-			srcResult := matchSrcNet(&proto.Rule{SrcNet: tc.nets}, &requestCache{srcFlow, nil})
-			dstResult := matchDstNet(&proto.Rule{DstNet: tc.nets}, &requestCache{dstFlow, nil})
+			srcResult := matchSrcNet(&proto.Rule{SrcNet: tc.nets}, &requestCache{Flow: srcFlow})
+			dstResult := matchDstNet(&proto.Rule{DstNet: tc.nets}, &requestCache{Flow: dstFlow})
 
 			Expect(srcResult).To(Equal(tc.srcResult), "Test case: %s", tc.title)
 			Expect(dstResult).To(Equal(tc.dstResult), "Test case: %s", tc.title)
@@ -858,6 +1010,39 @@ func TestMatchNets(t *testing.T) {
 	}
 }
 
+func TestMatchNetsNegated(t *testing.T) {
+	RegisterTestingT(t)
+
+	testCases := []struct {
+		title    string
+		nets     []string
+		notNets  []string
+		ip       string
+		expected bool
+	}{
+		{"no positive, not excluded", nil, []string{"192.168.0.0/16"}, "10.0.0.1", true},
+		{"no positive, excluded", nil, []string{"192.168.0.0/16"}, "192.168.1.1", false},
+		{"positive match, not excluded", []string{"10.0.0.0/8"}, []string{"192.168.0.0/16"}, "10.0.0.1", true},
+		{"positive match, but excluded", []string{"10.0.0.0/8"}, []string{"10.1.0.0/16"}, "10.1.2.3", false},
+		{"positive no match", []string{"10.0.0.0/8"}, nil, "192.168.1.1", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			ip := libnet.ParseIP(tc.ip).IP
+
+			srcFlow := &mocks.Flow{}
+			srcFlow.On("GetSourceIP").Return(ip)
+			dstFlow := &mocks.Flow{}
+			dstFlow.On("GetDestIP").Return(ip)
+
+			rule := &proto.Rule{SrcNet: tc.nets, NotSrcNet: tc.notNets, DstNet: tc.nets, NotDstNet: tc.notNets}
+			Expect(matchSrcNet(rule, &requestCache{Flow: srcFlow})).To(Equal(tc.expected), "Test case: %s", tc.title)
+			Expect(matchDstNet(rule, &requestCache{Flow: dstFlow})).To(Equal(tc.expected), "Test case: %s", tc.title)
+		})
+	}
+}
+
 func TestMatchDstIPPortSetIds(t *testing.T) {
 	RegisterTestingT(t)
 
@@ -949,6 +1134,26 @@ func TestMatchDstIPPortSetIds(t *testing.T) {
 			proto:    6,
 			expected: false,
 		},
+		{
+			title: "match v6 IP in set6",
+			rule: &proto.Rule{
+				DstIpPortSetIds: []string{"set6"},
+			},
+			destIP:   "2001:db8::1",
+			destPort: 80,
+			proto:    6,
+			expected: true,
+		},
+		{
+			title: "no match v6 IP in set6",
+			rule: &proto.Rule{
+				DstIpPortSetIds: []string{"set6"},
+			},
+			destIP:   "2001:db8::2",
+			destPort: 80,
+			proto:    6,
+			expected: false,
+		},
 	}
 
 	store := policystore.NewPolicyStore()
@@ -961,10 +1166,13 @@ func TestMatchDstIPPortSetIds(t *testing.T) {
 	setMulti.AddString("192.168.1.5,tcp:9090")
 	setProto := policystore.NewIPSet(proto.IPSetUpdate_IP)
 	setProto.AddString("192.168.1.7,udp:53")
+	set6 := policystore.NewIPSet(proto.IPSetUpdate_IP)
+	set6.AddString("[2001:db8::1]:tcp:80")
 	store.IPSetByID["set80"] = set80
 	store.IPSetByID["set443"] = set443
 	store.IPSetByID["setMulti"] = setMulti
 	store.IPSetByID["setProto"] = setProto
+	store.IPSetByID["set6"] = set6
 
 	for _, tc := range testCases {
 		t.Run(tc.title, func(t *testing.T) {
@@ -973,8 +1181,39 @@ func TestMatchDstIPPortSetIds(t *testing.T) {
 			fl.On("GetDestPort").Return(tc.destPort)
 			fl.On("GetProtocol").Return(tc.proto)
 
-			req := &requestCache{fl, store}
+			req := &requestCache{Flow: fl, store: store}
 			Expect(matchDstIPPortSetIds(tc.rule, req)).To(Equal(tc.expected), "Test case: %s", tc.title)
 		})
 	}
 }
+
+func TestMatchNotDstIPPortSetIds(t *testing.T) {
+	RegisterTestingT(t)
+
+	store := policystore.NewPolicyStore()
+	set80 := policystore.NewIPSet(proto.IPSetUpdate_IP)
+	set80.AddString("192.168.1.1,tcp:80")
+	store.IPSetByID["set80"] = set80
+
+	testCases := []struct {
+		title    string
+		destIP   string
+		expected bool
+	}{
+		{"excluded by not-set", "192.168.1.1", false},
+		{"not excluded by not-set", "192.168.1.9", true},
+	}
+
+	rule := &proto.Rule{NotDstIpPortSetIds: []string{"set80"}}
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			fl := &mocks.Flow{}
+			fl.On("GetDestIP").Return(libnet.ParseIP(tc.destIP).IP)
+			fl.On("GetDestPort").Return(80)
+			fl.On("GetProtocol").Return(6)
+
+			req := &requestCache{Flow: fl, store: store}
+			Expect(matchNotDstIPPortSetIds(rule, req)).To(Equal(tc.expected), "Test case: %s", tc.title)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mocks holds hand-written testify mocks for the checker package's
+// small internal interfaces.
+package mocks
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Flow is a testify mock implementing the checker.Flow interface, for use
+// in match_test.go table-driven tests that only care about one or two
+// attributes of the flow.
+type Flow struct {
+	mock.Mock
+}
+
+func (f *Flow) GetSourceIP() net.IP {
+	args := f.Called()
+	ip, _ := args.Get(0).(net.IP)
+	return ip
+}
+
+func (f *Flow) GetSourcePort() int {
+	args := f.Called()
+	return args.Int(0)
+}
+
+func (f *Flow) GetDestIP() net.IP {
+	args := f.Called()
+	ip, _ := args.Get(0).(net.IP)
+	return ip
+}
+
+func (f *Flow) GetDestPort() int {
+	args := f.Called()
+	return args.Int(0)
+}
+
+func (f *Flow) GetProtocol() int {
+	args := f.Called()
+	return args.Int(0)
+}
+
+func (f *Flow) GetSourcePrincipal() string {
+	args := f.Called()
+	return args.String(0)
+}
+
+func (f *Flow) GetDestPrincipal() string {
+	args := f.Called()
+	return args.String(0)
+}
+
+func (f *Flow) GetHTTPMethod() *string {
+	args := f.Called()
+	s, _ := args.Get(0).(*string)
+	return s
+}
+
+func (f *Flow) GetHTTPPath() *string {
+	args := f.Called()
+	s, _ := args.Get(0).(*string)
+	return s
+}
+
+func (f *Flow) GetHTTPHeaders() http.Header {
+	args := f.Called()
+	h, _ := args.Get(0).(http.Header)
+	return h
+}
+
+func (f *Flow) GetHTTPHost() string {
+	args := f.Called()
+	return args.String(0)
+}
+
+func (f *Flow) GetJWTClaims() *structpb.Struct {
+	args := f.Called()
+	s, _ := args.Get(0).(*structpb.Struct)
+	return s
+}
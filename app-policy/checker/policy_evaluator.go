@@ -0,0 +1,151 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/projectcalico/calico/app-policy/policystore"
+	"github.com/projectcalico/calico/felix/proto"
+)
+
+// PolicyEvaluator is the entry point a real CheckRequest handler should
+// evaluate one policy's rules through once one exists: it combines a
+// CompiledPolicyCache (so repeated flows don't re-walk every rule's
+// SrcNet/DstNet/port clauses linearly) with a DecisionCache per policy (so
+// repeated identical flows against the same policy skip rule evaluation
+// entirely once a decision has been cached for the current PolicyStore
+// generation). As of this writing app-policy/server has no Check/
+// StreamChannel handler yet (see the package comment on
+// app-policy/server/interceptor.go) -- like CheckRule before it,
+// PolicyEvaluator.CheckPolicy is exercised only by this package's own
+// tests until that handler lands and calls it per policy tier.
+//
+// PolicyEvaluator is safe for concurrent use.
+type PolicyEvaluator struct {
+	compiled *CompiledPolicyCache
+
+	decisionCacheCapacity int
+	logRatePerSecond      float64
+	logBurst              float64
+	limiterCapacity       int
+
+	mu        sync.Mutex
+	decisions map[string]*DecisionCache
+}
+
+// NewPolicyEvaluator creates a PolicyEvaluator. decisionCacheCapacity and
+// limiterCapacity bound, respectively, the number of cached decisions and
+// the number of per-5-tuple rate limiters each policy's DecisionCache
+// holds; logRatePerSecond/logBurst configure the rate limiter itself. See
+// NewDecisionCache for the meaning of each.
+func NewPolicyEvaluator(decisionCacheCapacity int, logRatePerSecond, logBurst float64, limiterCapacity int) *PolicyEvaluator {
+	return &PolicyEvaluator{
+		compiled:              NewCompiledPolicyCache(),
+		decisionCacheCapacity: decisionCacheCapacity,
+		logRatePerSecond:      logRatePerSecond,
+		logBurst:              logBurst,
+		limiterCapacity:       limiterCapacity,
+		decisions:             make(map[string]*DecisionCache),
+	}
+}
+
+// decisionCacheFor returns the DecisionCache for policyKey, creating it on
+// first use. Each policy gets its own DecisionCache, rather than sharing
+// one keyed additionally by policy, so that a DecisionKey (which only
+// identifies a flow, not a policy) can never be served a cached decision
+// computed for a different policy.
+func (e *PolicyEvaluator) decisionCacheFor(policyKey string) *DecisionCache {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	dc, ok := e.decisions[policyKey]
+	if !ok {
+		dc = NewDecisionCache(e.decisionCacheCapacity, e.logRatePerSecond, e.logBurst, e.limiterCapacity)
+		e.decisions[policyKey] = dc
+	}
+	return dc
+}
+
+// CheckPolicy evaluates rules (a single policy's rule list, as seen under
+// policyNamespace) against req, returning the action of the first matching
+// rule and ok=true, or ok=false if no rule matches. tier and policyName
+// identify the policy for the compiled-rule and decision caches, and (when
+// store.Tracing.Enabled) for the span this opens.
+//
+// A cached decision is only consulted/stored for the Allow/Deny actions;
+// any other action (e.g. Pass, Log) always falls through to full
+// evaluation, since DecisionCache only has room to remember a boolean.
+//
+// logDenial reports whether the caller should log this call's Deny
+// decision: it is only ever true alongside action == "Deny", and is
+// already rate-limited per connection, so the caller doesn't need (and
+// shouldn't apply) any further throttling before logging it.
+//
+// Unlike CheckRule, store must not be nil: CheckPolicy always needs it, to
+// key cached decisions by generation, whether or not tracing is enabled.
+func (e *PolicyEvaluator) CheckPolicy(ctx context.Context, store *policystore.PolicyStore, tier, policyName, policyNamespace string, rules []*proto.Rule, req *requestCache) (action string, ok bool, logDenial bool) {
+	policyKey := tier + "/" + policyName
+	dc := e.decisionCacheFor(policyKey)
+	dkey := NewDecisionKey(req.GetSourceIP(), req.GetDestIP(), req.GetDestPort(), req.GetProtocol(), store.Generation())
+
+	if allowed, hit := dc.Get(dkey); hit {
+		if allowed {
+			return "Allow", true, false
+		}
+		return "Deny", true, e.shouldLogDenial(dc, req)
+	}
+
+	var ruleID int
+	if !store.Tracing.Enabled {
+		action, ruleID, ok = e.compiled.Get(policyKey, policyNamespace, rules, store).Match(ctx, req)
+	} else {
+		var span trace.Span
+		ctx, span = otel.Tracer(tracerName).Start(ctx, "checker.CheckPolicy")
+		defer span.End()
+		span.SetAttributes(
+			attribute.String("policy.tier", tier),
+			attribute.String("policy.name", policyName),
+		)
+
+		action, ruleID, ok = e.compiled.Get(policyKey, policyNamespace, rules, store).Match(ctx, req)
+		span.SetAttributes(attribute.Bool("checker.matched", ok))
+		if ok {
+			span.SetAttributes(
+				attribute.Int("policy.rule_index", ruleID),
+				attribute.String("checker.decision", actionDecision(action)),
+			)
+		}
+	}
+
+	if ok && (action == "Allow" || action == "Deny") {
+		allowed := action == "Allow"
+		dc.Put(dkey, allowed)
+		if !allowed {
+			return action, ok, e.shouldLogDenial(dc, req)
+		}
+	}
+	return action, ok, false
+}
+
+// shouldLogDenial consults dc's per-connection rate limiter for req.
+func (e *PolicyEvaluator) shouldLogDenial(dc *DecisionCache, req *requestCache) bool {
+	return dc.ShouldLogDenial(req.GetSourceIP(), req.GetSourcePort(), req.GetDestIP(), req.GetDestPort(), req.GetProtocol())
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/calico/app-policy/policystore"
+	"github.com/projectcalico/calico/felix/proto"
+)
+
+func TestPolicyEvaluatorCachesDecisionAcrossCalls(t *testing.T) {
+	RegisterTestingT(t)
+
+	store := policystore.NewPolicyStore()
+	rules := []*proto.Rule{{Action: "Allow", DstNet: []string{"10.0.0.0/8"}}}
+	req := compiledPolicyTestRequest(store, "10.1.1.1", "10.0.0.5", 443)
+
+	e := NewPolicyEvaluator(10, 1, 1, 10)
+
+	action, ok, logDenial := e.CheckPolicy(context.Background(), store, "default", "allow-internal", "", rules, req)
+	Expect(ok).To(BeTrue())
+	Expect(action).To(Equal("Allow"))
+	Expect(logDenial).To(BeFalse())
+
+	// Change the rule list without bumping the store: a second call for the
+	// same flow must be served from the decision cache rather than
+	// re-evaluating the (now different) rules.
+	rules = []*proto.Rule{{Action: "Deny", DstNet: []string{"10.0.0.0/8"}}}
+	action, ok, logDenial = e.CheckPolicy(context.Background(), store, "default", "allow-internal", "", rules, req)
+	Expect(ok).To(BeTrue())
+	Expect(action).To(Equal("Allow"))
+	Expect(logDenial).To(BeFalse())
+}
+
+func TestPolicyEvaluatorBumpInvalidatesCachedDecision(t *testing.T) {
+	RegisterTestingT(t)
+
+	store := policystore.NewPolicyStore()
+	rules := []*proto.Rule{{Action: "Allow", DstNet: []string{"10.0.0.0/8"}}}
+	req := compiledPolicyTestRequest(store, "10.1.1.1", "10.0.0.5", 443)
+
+	e := NewPolicyEvaluator(10, 1, 1, 10)
+	_, ok, _ := e.CheckPolicy(context.Background(), store, "default", "allow-internal", "", rules, req)
+	Expect(ok).To(BeTrue())
+
+	store.Bump()
+	rules = []*proto.Rule{{Action: "Deny", DstNet: []string{"10.0.0.0/8"}}}
+	action, ok, logDenial := e.CheckPolicy(context.Background(), store, "default", "allow-internal", "", rules, req)
+	Expect(ok).To(BeTrue())
+	Expect(action).To(Equal("Deny"))
+	Expect(logDenial).To(BeTrue())
+}
+
+func TestPolicyEvaluatorDifferentPoliciesDontShareDecisions(t *testing.T) {
+	RegisterTestingT(t)
+
+	store := policystore.NewPolicyStore()
+	allowRules := []*proto.Rule{{Action: "Allow", DstNet: []string{"10.0.0.0/8"}}}
+	denyRules := []*proto.Rule{{Action: "Deny", DstNet: []string{"10.0.0.0/8"}}}
+	req := compiledPolicyTestRequest(store, "10.1.1.1", "10.0.0.5", 443)
+
+	e := NewPolicyEvaluator(10, 1, 1, 10)
+
+	action, ok, _ := e.CheckPolicy(context.Background(), store, "default", "allow-internal", "", allowRules, req)
+	Expect(ok).To(BeTrue())
+	Expect(action).To(Equal("Allow"))
+
+	// A different policy name, same flow: must not be served the first
+	// policy's cached Allow decision.
+	action, ok, logDenial := e.CheckPolicy(context.Background(), store, "default", "deny-internal", "", denyRules, req)
+	Expect(ok).To(BeTrue())
+	Expect(action).To(Equal("Deny"))
+	Expect(logDenial).To(BeTrue())
+}
+
+func TestPolicyEvaluatorNoMatchIsNotCached(t *testing.T) {
+	RegisterTestingT(t)
+
+	store := policystore.NewPolicyStore()
+	rules := []*proto.Rule{{Action: "Allow", DstNet: []string{"192.168.0.0/16"}}}
+	req := compiledPolicyTestRequest(store, "10.1.1.1", "10.0.0.5", 443)
+
+	e := NewPolicyEvaluator(10, 1, 1, 10)
+	_, ok, logDenial := e.CheckPolicy(context.Background(), store, "default", "allow-internal", "", rules, req)
+	Expect(ok).To(BeFalse())
+	Expect(logDenial).To(BeFalse())
+}
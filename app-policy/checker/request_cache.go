@@ -0,0 +1,213 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	auth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/projectcalico/calico/app-policy/policystore"
+)
+
+// jwtAuthnMetadataKey is the well-known filter name Envoy's jwt_authn HTTP
+// filter publishes verified JWT payloads under in metadata_context.
+const jwtAuthnMetadataKey = "envoy.filters.http.jwt_authn"
+
+// Flow is the set of attributes of a single connection/request that the
+// rule matchers need. It is implemented by checkRequestToFlowAdapter for
+// real ext_authz traffic, and by mocks.Flow in tests.
+type Flow interface {
+	GetSourceIP() net.IP
+	GetSourcePort() int
+	GetDestIP() net.IP
+	GetDestPort() int
+	GetProtocol() int
+	GetSourcePrincipal() string
+	GetDestPrincipal() string
+	GetHTTPMethod() *string
+	GetHTTPPath() *string
+	// GetHTTPHeaders returns the request's HTTP headers, keyed
+	// case-insensitively as http.Header does. It never includes the
+	// synthetic ":authority"/"Host" pseudo-header; use GetHTTPHost for that.
+	GetHTTPHeaders() http.Header
+	GetHTTPHost() string
+	// GetJWTClaims returns the verified JWT payload Envoy's jwt_authn
+	// filter attached to the request's metadata_context, or nil if the
+	// request carries no verified JWT.
+	GetJWTClaims() *structpb.Struct
+}
+
+// requestCache bundles together the flow under evaluation with the policy
+// state needed to resolve IP sets, namespaces and other indirect matches.
+// It is built once per CheckRequest and threaded through every rule
+// evaluated for that request, which is also where we cache anything that
+// is expensive to recompute (e.g. compiled regexes).
+type requestCache struct {
+	Flow
+	store *policystore.PolicyStore
+
+	// jwtClaims/jwtClaimsRead memoize Flow.GetJWTClaims(), and claimByPath
+	// memoizes dotted-path lookups into it, so that a request with many
+	// rules referencing JWTMatch clauses only decodes/walks the JWT
+	// payload once rather than once per rule.
+	jwtClaims     *structpb.Struct
+	jwtClaimsRead bool
+	claimByPath   map[string]*structpb.Value
+}
+
+// NewRequestCache creates the per-CheckRequest cache used while evaluating
+// a policy store's rules against flow.
+func NewRequestCache(store *policystore.PolicyStore, flow Flow) *requestCache {
+	return &requestCache{Flow: flow, store: store}
+}
+
+// jwtClaimsCached returns the request's verified JWT payload, decoding it
+// from the underlying Flow on first use.
+func (c *requestCache) jwtClaimsCached() *structpb.Struct {
+	if !c.jwtClaimsRead {
+		c.jwtClaims = c.Flow.GetJWTClaims()
+		c.jwtClaimsRead = true
+	}
+	return c.jwtClaims
+}
+
+// resolveClaimPath walks path (a dot-separated JSON path, e.g.
+// "realm_access.roles") through the request's JWT claims, caching the
+// result by path so that a rule set referencing the same claim path
+// multiple times only walks the claim structure once per request.
+func (c *requestCache) resolveClaimPath(path string) (*structpb.Value, bool) {
+	if v, ok := c.claimByPath[path]; ok {
+		return v, true
+	}
+	v, ok := walkClaimPath(c.jwtClaimsCached(), path)
+	if !ok {
+		return nil, false
+	}
+	if c.claimByPath == nil {
+		c.claimByPath = make(map[string]*structpb.Value)
+	}
+	c.claimByPath[path] = v
+	return v, true
+}
+
+// checkRequestToFlowAdapter adapts an Envoy ext_authz CheckRequest to the
+// Flow interface used by the rule matchers.
+type checkRequestToFlowAdapter struct {
+	req *auth.CheckRequest
+}
+
+// NewCheckRequestToFlowAdapter wraps an Envoy ext_authz CheckRequest so it
+// can be evaluated against policy rules via the Flow interface.
+func NewCheckRequestToFlowAdapter(req *auth.CheckRequest) Flow {
+	return &checkRequestToFlowAdapter{req: req}
+}
+
+func (a *checkRequestToFlowAdapter) GetSourceIP() net.IP {
+	return net.ParseIP(a.req.GetAttributes().GetSource().GetAddress().GetSocketAddress().GetAddress())
+}
+
+func (a *checkRequestToFlowAdapter) GetSourcePort() int {
+	return int(a.req.GetAttributes().GetSource().GetAddress().GetSocketAddress().GetPortValue())
+}
+
+func (a *checkRequestToFlowAdapter) GetDestIP() net.IP {
+	return net.ParseIP(a.req.GetAttributes().GetDestination().GetAddress().GetSocketAddress().GetAddress())
+}
+
+func (a *checkRequestToFlowAdapter) GetDestPort() int {
+	return int(a.req.GetAttributes().GetDestination().GetAddress().GetSocketAddress().GetPortValue())
+}
+
+// GetProtocol returns the IANA protocol number for the destination socket,
+// defaulting to TCP (6) when the dataplane didn't tell us otherwise.
+func (a *checkRequestToFlowAdapter) GetProtocol() int {
+	switch a.req.GetAttributes().GetDestination().GetAddress().GetSocketAddress().GetProtocol() {
+	case 1: // core.SocketAddress_UDP
+		return 17
+	default:
+		return 6
+	}
+}
+
+func (a *checkRequestToFlowAdapter) GetSourcePrincipal() string {
+	return a.req.GetAttributes().GetSource().GetPrincipal()
+}
+
+func (a *checkRequestToFlowAdapter) GetDestPrincipal() string {
+	return a.req.GetAttributes().GetDestination().GetPrincipal()
+}
+
+func (a *checkRequestToFlowAdapter) GetHTTPMethod() *string {
+	m := a.req.GetAttributes().GetRequest().GetHttp().GetMethod()
+	return &m
+}
+
+func (a *checkRequestToFlowAdapter) GetHTTPPath() *string {
+	p := a.req.GetAttributes().GetRequest().GetHttp().GetPath()
+	return &p
+}
+
+func (a *checkRequestToFlowAdapter) GetHTTPHeaders() http.Header {
+	headers := http.Header{}
+	for name, value := range a.req.GetAttributes().GetRequest().GetHttp().GetHeaders() {
+		if strings.EqualFold(name, "host") || name == ":authority" {
+			continue
+		}
+		headers.Add(name, value)
+	}
+	return headers
+}
+
+func (a *checkRequestToFlowAdapter) GetHTTPHost() string {
+	headers := a.req.GetAttributes().GetRequest().GetHttp().GetHeaders()
+	if host, ok := headers[":authority"]; ok {
+		return host
+	}
+	return headers["host"]
+}
+
+func (a *checkRequestToFlowAdapter) GetJWTClaims() *structpb.Struct {
+	return a.req.GetAttributes().GetMetadataContext().GetFilterMetadata()[jwtAuthnMetadataKey]
+}
+
+// principalToNamespace extracts the Kubernetes namespace from a SPIFFE
+// identity of the form spiffe://<trust-domain>/ns/<namespace>/sa/<name>.
+// It returns "" if the principal isn't a recognised SPIFFE ID.
+func principalToNamespace(principal string) string {
+	parts := strings.Split(principal, "/")
+	for i, p := range parts {
+		if p == "ns" && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// principalToServiceAccount extracts the Kubernetes service account name
+// from a SPIFFE identity of the form spiffe://<trust-domain>/ns/<namespace>/sa/<name>.
+// It returns "" if the principal isn't a recognised SPIFFE ID.
+func principalToServiceAccount(principal string) string {
+	parts := strings.Split(principal, "/")
+	for i, p := range parts {
+		if p == "sa" && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
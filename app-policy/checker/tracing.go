@@ -0,0 +1,122 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/projectcalico/calico/app-policy/policystore"
+	"github.com/projectcalico/calico/felix/proto"
+)
+
+const tracerName = "github.com/projectcalico/calico/app-policy/checker"
+
+// traceContextPropagator extracts the W3C traceparent/tracestate headers
+// Envoy forwards on the CheckRequest, so the app-policy decision shows up
+// as a child of the caller's span rather than starting a new trace.
+var traceContextPropagator = propagation.TraceContext{}
+
+// RuleMeta identifies the policy/tier/rule a CheckRule call is evaluating,
+// for span attributes. It carries no behaviour of its own.
+type RuleMeta struct {
+	Tier      string
+	Policy    string
+	RuleIndex int
+}
+
+// StartCheckSpan starts the single OpenTelemetry span for one CheckRequest,
+// extracting any W3C trace context carried in req's HTTP headers so the
+// span is a child of the caller's, not the root of a new trace. It must be
+// called exactly once per CheckRequest, before CheckRule is called for each
+// rule the request is evaluated against; CheckRule opens its own per-rule
+// child span under the context this returns. Callers must End() the
+// returned span once the request's rules have all been evaluated.
+//
+// With tracing disabled (store.Tracing.Enabled is false, or store is nil)
+// this returns ctx unchanged and a no-op span, so CheckRule's per-rule
+// overhead stays limited to a single boolean check on the default hot path.
+func StartCheckSpan(ctx context.Context, store *policystore.PolicyStore, req *requestCache) (context.Context, trace.Span) {
+	if store == nil || !store.Tracing.Enabled {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	ctx = traceContextPropagator.Extract(ctx, propagation.HeaderCarrier(req.GetHTTPHeaders()))
+	return otel.Tracer(tracerName).Start(ctx, "checker.Check")
+}
+
+// CheckRule evaluates rule against the flow in req, recording an
+// OpenTelemetry span for the call when store.Tracing.Enabled is set. ctx
+// must come from StartCheckSpan (called once per CheckRequest, not per
+// rule), so the span this opens is a child of that single per-request span
+// rather than a disconnected root of its own.
+func CheckRule(ctx context.Context, store *policystore.PolicyStore, meta RuleMeta, policyNamespace string, rule *proto.Rule, req *requestCache) bool {
+	if store == nil || !store.Tracing.Enabled {
+		return match(ctx, policyNamespace, rule, req)
+	}
+
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "checker.CheckRule")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("policy.tier", meta.Tier),
+		attribute.String("policy.name", meta.Policy),
+		attribute.Int("policy.rule_index", meta.RuleIndex),
+		attribute.String("source.service_account", principalToServiceAccount(req.GetSourcePrincipal())),
+		attribute.String("destination.service_account", principalToServiceAccount(req.GetDestPrincipal())),
+		attribute.Int("network.protocol_number", req.GetProtocol()),
+	)
+
+	matched := match(ctx, policyNamespace, rule, req)
+
+	decision := "pass"
+	if matched {
+		decision = actionDecision(rule.Action)
+	}
+	span.SetAttributes(attribute.String("checker.decision", decision))
+
+	return matched
+}
+
+// actionDecision maps a rule's Action to the allow/deny vocabulary used in
+// span attributes and logs.
+func actionDecision(action string) string {
+	switch action {
+	case "Deny":
+		return "deny"
+	case "Allow":
+		return "allow"
+	default:
+		return "pass"
+	}
+}
+
+// traceBool runs fn inside a child span named name, when tracing is
+// enabled for req's store, and returns fn's result unchanged. With tracing
+// disabled it just calls fn, so there's no span/context overhead on the
+// default hot path.
+func traceBool(ctx context.Context, req *requestCache, name string, fn func() bool) bool {
+	if req.store == nil || !req.store.Tracing.Enabled {
+		return fn()
+	}
+	_, span := otel.Tracer(tracerName).Start(ctx, name)
+	defer span.End()
+	result := fn()
+	span.SetAttributes(attribute.Bool("checker.matched", result))
+	return result
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	auth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/projectcalico/calico/app-policy/policystore"
+	"github.com/projectcalico/calico/felix/proto"
+)
+
+// withRecordedSpans installs an in-memory span recorder as the global
+// OpenTelemetry TracerProvider for the duration of fn, restoring whatever
+// was installed before. Returns the spans recorded during fn, in the order
+// they ended.
+func withRecordedSpans(fn func()) []sdktrace.ReadOnlySpan {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	fn()
+
+	return exporter.GetSpans().Snapshots()
+}
+
+func tracedCheckRequest() *auth.CheckRequest {
+	return &auth.CheckRequest{Attributes: &auth.AttributeContext{
+		Source: &auth.AttributeContext_Peer{
+			Principal: "spiffe://cluster.local/ns/default/sa/sam",
+			Address: &core.Address{Address: &core.Address_SocketAddress{
+				SocketAddress: &core.SocketAddress{
+					Address:       "192.168.4.22",
+					Protocol:      core.SocketAddress_TCP,
+					PortSpecifier: &core.SocketAddress_PortValue{PortValue: 8458},
+				}}},
+		},
+		Destination: &auth.AttributeContext_Peer{
+			Principal: "spiffe://cluster.local/ns/default/sa/ian",
+			Address: &core.Address{Address: &core.Address_SocketAddress{
+				SocketAddress: &core.SocketAddress{
+					Address:       "10.54.44.23",
+					Protocol:      core.SocketAddress_TCP,
+					PortSpecifier: &core.SocketAddress_PortValue{PortValue: 80},
+				}}},
+		},
+		Request: &auth.AttributeContext_Request{
+			Http: &auth.AttributeContext_HttpRequest{
+				Method: "GET",
+				Path:   "/path",
+				Headers: map[string]string{
+					"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+				},
+			},
+		},
+	}}
+}
+
+func TestCheckRuleDisabledEmitsNoSpans(t *testing.T) {
+	RegisterTestingT(t)
+
+	store := policystore.NewPolicyStore()
+	rule := &proto.Rule{Action: "Allow", HttpMatch: &proto.HTTPMatch{Paths: []*proto.HTTPMatch_PathMatch{
+		{PathMatch: &proto.HTTPMatch_PathMatch_Exact{Exact: "/path"}},
+	}}}
+	reqCache := NewRequestCache(store, NewCheckRequestToFlowAdapter(tracedCheckRequest()))
+
+	spans := withRecordedSpans(func() {
+		ctx, span := StartCheckSpan(context.Background(), store, reqCache)
+		matched := CheckRule(ctx, store, RuleMeta{}, "", rule, reqCache)
+		span.End()
+		Expect(matched).To(BeTrue())
+	})
+	Expect(spans).To(BeEmpty())
+}
+
+func TestCheckRuleEmitsSpanWithAttributesAndParent(t *testing.T) {
+	RegisterTestingT(t)
+
+	store := policystore.NewPolicyStore()
+	store.Tracing.Enabled = true
+	rule := &proto.Rule{Action: "Allow", HttpMatch: &proto.HTTPMatch{Paths: []*proto.HTTPMatch_PathMatch{
+		{PathMatch: &proto.HTTPMatch_PathMatch_Exact{Exact: "/path"}},
+	}}}
+	reqCache := NewRequestCache(store, NewCheckRequestToFlowAdapter(tracedCheckRequest()))
+	meta := RuleMeta{Tier: "default", Policy: "allow-api", RuleIndex: 2}
+	otherMeta := RuleMeta{Tier: "default", Policy: "deny-other", RuleIndex: 0}
+	denyRule := &proto.Rule{Action: "Deny", HttpMatch: &proto.HTTPMatch{Paths: []*proto.HTTPMatch_PathMatch{
+		{PathMatch: &proto.HTTPMatch_PathMatch_Exact{Exact: "/other"}},
+	}}}
+
+	spans := withRecordedSpans(func() {
+		// A real CheckRequest evaluates many rules; StartCheckSpan must be
+		// called once for the whole request, with every CheckRule call
+		// (one per rule) nesting under the single span it returns instead
+		// of each starting its own root.
+		ctx, span := StartCheckSpan(context.Background(), store, reqCache)
+		matched := CheckRule(ctx, store, meta, "default", rule, reqCache)
+		Expect(matched).To(BeTrue())
+		matched = CheckRule(ctx, store, otherMeta, "default", denyRule, reqCache)
+		Expect(matched).To(BeFalse())
+		span.End()
+	})
+
+	var root, ruleSpan, otherRuleSpan, httpChild sdktrace.ReadOnlySpan
+	for _, s := range spans {
+		switch s.Name() {
+		case "checker.Check":
+			root = s
+		case "checker.http_match":
+			httpChild = s
+		case "checker.CheckRule":
+			for _, a := range s.Attributes() {
+				if a.Key != "policy.name" {
+					continue
+				}
+				switch a.Value.AsString() {
+				case "allow-api":
+					ruleSpan = s
+				case "deny-other":
+					otherRuleSpan = s
+				}
+			}
+		}
+	}
+	Expect(root).ToNot(BeNil())
+	Expect(ruleSpan).ToNot(BeNil())
+	Expect(otherRuleSpan).ToNot(BeNil())
+	Expect(httpChild).ToNot(BeNil())
+
+	// The extracted traceparent's trace ID should be the one the root
+	// span was created under, i.e. the span is a child of the caller.
+	Expect(root.SpanContext().TraceID().String()).To(Equal("0af7651916cd43dd8448eb211c80319c"))
+	Expect(root.Parent().SpanID().String()).To(Equal("b7ad6b7169203331"))
+
+	// Both per-rule spans must be children of the single root
+	// checker.Check span, not disconnected roots of their own.
+	Expect(ruleSpan.Parent().SpanID()).To(Equal(root.SpanContext().SpanID()))
+	Expect(otherRuleSpan.Parent().SpanID()).To(Equal(root.SpanContext().SpanID()))
+
+	// checker.http_match must be a child of the checker.CheckRule span it
+	// was evaluated under, not the shared root.
+	Expect(httpChild.Parent().SpanID()).To(Equal(ruleSpan.SpanContext().SpanID()))
+
+	attrs := map[string]string{}
+	for _, a := range ruleSpan.Attributes() {
+		attrs[string(a.Key)] = a.Value.Emit()
+	}
+	Expect(attrs["policy.tier"]).To(Equal("default"))
+	Expect(attrs["policy.name"]).To(Equal("allow-api"))
+	Expect(attrs["policy.rule_index"]).To(Equal("2"))
+	Expect(attrs["source.service_account"]).To(Equal("sam"))
+	Expect(attrs["destination.service_account"]).To(Equal("ian"))
+	Expect(attrs["checker.decision"]).To(Equal("allow"))
+}
+
+func TestTraceContextPropagatorIsW3C(t *testing.T) {
+	RegisterTestingT(t)
+	// Sanity check that we're using the standard W3C TraceContext
+	// propagator rather than a one-off header parser.
+	Expect(traceContextPropagator.Fields()).To(ConsistOf(propagation.TraceContext{}.Fields()))
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policystore
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/projectcalico/calico/felix/proto"
+)
+
+// IPSet is the checker's in-memory copy of a Felix IP set. Members are
+// stored as the raw strings Felix sends on the wire: a bare address for
+// IPSetUpdate_IP sets, or "addr,proto:port" for IPSetUpdate_IP_AND_PORT
+// sets -- where proto:port may be a single port ("tcp:80"), an inclusive
+// range ("tcp:23-24") or a wildcard ("tcp:*"), and addr may itself be a
+// CIDR rather than a single address. IPSetUpdate_NET sets hold CIDRs
+// rather than bare addresses, so membership is a longest-prefix lookup
+// rather than an exact one; those are kept in a NetTrie instead of the
+// members map so a lookup stays O(prefix length) regardless of how many
+// CIDRs the set has.
+type IPSet struct {
+	Type    proto.IPSetUpdate_IPSetType
+	members map[string]bool
+	nets    *NetTrie
+	ports   *NetTrie
+}
+
+// NewIPSet creates an empty IPSet of the given kind.
+func NewIPSet(t proto.IPSetUpdate_IPSetType) *IPSet {
+	s := &IPSet{
+		Type:    t,
+		members: make(map[string]bool),
+		ports:   NewNetTrie(),
+	}
+	if t == proto.IPSetUpdate_NET {
+		s.nets = NewNetTrie()
+	}
+	return s
+}
+
+// AddString adds a member in Felix's wire format to the set. IPv4 members
+// use a bare address ("192.168.1.1") or "addr,proto:port"
+// ("192.168.1.1,tcp:80"); IPv6 members are bracketed the way Felix
+// disambiguates the embedded colons ("[2001:db8::1]" or
+// "[2001:db8::1]:tcp:80"). addr may be a CIDR instead of a single address
+// ("0.0.0.0/0,tcp:443"), and the port half of a proto:port entry may be a
+// range ("tcp:23-24") or wildcard ("tcp:*") rather than a single port.
+// Addresses are normalized to net.IP's canonical string form before being
+// stored, so membership tests don't depend on Felix and the checker
+// agreeing on IPv6 zero-compression byte-for-byte.
+func (s *IPSet) AddString(member string) {
+	if s.nets != nil {
+		// Ignore malformed CIDRs rather than aborting the rest of the
+		// snapshot/delta update; Felix is expected to only ever send
+		// well-formed CIDRs for a NET set.
+		_ = s.nets.Insert(member, member)
+		return
+	}
+	addr, portSpec, hasPort := splitAddrAndProtoPort(member)
+	if !hasPort {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return
+		}
+		s.members[ip.String()] = true
+		return
+	}
+	cidr, ok := toCIDR(addr)
+	if !ok {
+		return
+	}
+	owner, ok := parsePortSpec(portSpec)
+	if !ok {
+		return
+	}
+	_ = s.ports.Insert(cidr, owner)
+}
+
+// RemoveString removes a member previously added with AddString.
+func (s *IPSet) RemoveString(member string) {
+	if s.nets != nil {
+		_ = s.nets.Delete(member, member)
+		return
+	}
+	addr, portSpec, hasPort := splitAddrAndProtoPort(member)
+	if !hasPort {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return
+		}
+		delete(s.members, ip.String())
+		return
+	}
+	cidr, ok := toCIDR(addr)
+	if !ok {
+		return
+	}
+	owner, ok := parsePortSpec(portSpec)
+	if !ok {
+		return
+	}
+	_ = s.ports.Delete(cidr, owner)
+}
+
+// ContainsAddress returns true if the plain address ip is a member of the
+// set. Used for SrcIpSetIds/DstIpSetIds matching, where set members are
+// bare addresses (or, for IPSetUpdate_NET sets, CIDRs that contain ip). ip
+// may be IPv4 or IPv6; a set built from addresses of one family never
+// matches an address of the other.
+func (s *IPSet) ContainsAddress(ip net.IP) bool {
+	if s == nil || ip == nil {
+		return false
+	}
+	if s.nets != nil {
+		return len(s.nets.LongestMatch(ip)) > 0
+	}
+	return s.members[ip.String()]
+}
+
+// ContainsAddressPort returns true if ip,proto:port is a member of the
+// set. Used for DstIpPortSetIds matching, where set members combine an
+// address (or CIDR) with an L4 protocol and a port or port range. ip may
+// be IPv4 or IPv6. Entries of differing specificity (e.g. a /0 and a /32
+// naming different ports) are all considered, not just the most specific
+// CIDR, since a shorter prefix's port range doesn't override a longer
+// one's the way NET-set CIDRs do.
+func (s *IPSet) ContainsAddressPort(ip net.IP, protocol string, port int) bool {
+	if s == nil || ip == nil {
+		return false
+	}
+	protocol = strings.ToLower(protocol)
+	for _, owner := range s.ports.AllMatches(ip) {
+		p, first, last, ok := parsePortOwner(owner)
+		if ok && p == protocol && port >= first && port <= last {
+			return true
+		}
+	}
+	return false
+}
+
+// toCIDR turns a bare address or CIDR into a CIDR string suitable for
+// NetTrie.Insert/Delete, host-routing a bare address to a /32 (IPv4) or
+// /128 (IPv6) prefix so it can share the same trie as genuine CIDR
+// entries.
+func toCIDR(addr string) (string, bool) {
+	if strings.Contains(addr, "/") {
+		if _, _, err := net.ParseCIDR(addr); err != nil {
+			return "", false
+		}
+		return addr, true
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", false
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.String() + "/32", true
+	}
+	return ip.String() + "/128", true
+}
+
+// parsePortSpec parses the "proto:port" half of an IP_AND_PORT member --
+// a single port ("tcp:80"), an inclusive range ("tcp:23-24") or a
+// wildcard covering every port ("tcp:*") -- into the owner string stored
+// against its address/CIDR in IPSet.ports. protocol is lower-cased so
+// lookups don't depend on the case Felix happens to send.
+func parsePortSpec(spec string) (owner string, ok bool) {
+	protocol, portPart, found := strings.Cut(spec, ":")
+	if !found {
+		return "", false
+	}
+	protocol = strings.ToLower(protocol)
+
+	if portPart == "*" {
+		return portOwner(protocol, 0, 65535), true
+	}
+	if first, last, found := strings.Cut(portPart, "-"); found {
+		f, err1 := strconv.Atoi(first)
+		l, err2 := strconv.Atoi(last)
+		if err1 != nil || err2 != nil || f > l {
+			return "", false
+		}
+		return portOwner(protocol, f, l), true
+	}
+	p, err := strconv.Atoi(portPart)
+	if err != nil {
+		return "", false
+	}
+	return portOwner(protocol, p, p), true
+}
+
+// portOwner and parsePortOwner encode/decode the (protocol, first, last)
+// triple that parsePortSpec produces as the opaque owner string NetTrie
+// stores, so ContainsAddressPort can recover it from AllMatches.
+func portOwner(protocol string, first, last int) string {
+	return protocol + ":" + strconv.Itoa(first) + "-" + strconv.Itoa(last)
+}
+
+func parsePortOwner(owner string) (protocol string, first int, last int, ok bool) {
+	protocol, portRange, found := strings.Cut(owner, ":")
+	if !found {
+		return "", 0, 0, false
+	}
+	firstStr, lastStr, found := strings.Cut(portRange, "-")
+	if !found {
+		return "", 0, 0, false
+	}
+	f, err1 := strconv.Atoi(firstStr)
+	l, err2 := strconv.Atoi(lastStr)
+	if err1 != nil || err2 != nil {
+		return "", 0, 0, false
+	}
+	return protocol, f, l, true
+}
+
+// splitAddrAndProtoPort splits a wire-format member into its address (or
+// CIDR) and (if present) "proto:port" suffix. IPv6 addresses are
+// bracketed ("[2001:db8::1]:tcp:80") since the address itself contains
+// colons; IPv4 addresses and CIDRs are comma-separated
+// ("192.168.1.1,tcp:80", "0.0.0.0/0,tcp:443").
+func splitAddrAndProtoPort(member string) (addr string, protoPort string, hasPort bool) {
+	if strings.HasPrefix(member, "[") {
+		end := strings.IndexByte(member, ']')
+		if end < 0 {
+			return member, "", false
+		}
+		addr = member[1:end]
+		if end+1 < len(member) && member[end+1] == ':' {
+			return addr, member[end+2:], true
+		}
+		return addr, "", false
+	}
+	if before, after, found := strings.Cut(member, ","); found {
+		return before, after, true
+	}
+	return member, "", false
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policystore
+
+import (
+	"net"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/calico/felix/proto"
+)
+
+func TestIPSetContainsAddressV6(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := NewIPSet(proto.IPSetUpdate_IP)
+	s.AddString("2001:db8::1")
+
+	Expect(s.ContainsAddress(net.ParseIP("2001:db8::1"))).To(BeTrue())
+	// Same address, different zero-compression: still a hit.
+	Expect(s.ContainsAddress(net.ParseIP("2001:0db8:0000:0000:0000:0000:0000:0001"))).To(BeTrue())
+	Expect(s.ContainsAddress(net.ParseIP("2001:db8::2"))).To(BeFalse())
+}
+
+func TestIPSetContainsAddressPortV6(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := NewIPSet(proto.IPSetUpdate_IP)
+	s.AddString("[2001:db8::1]:tcp:80")
+
+	Expect(s.ContainsAddressPort(net.ParseIP("2001:db8::1"), "tcp", 80)).To(BeTrue())
+	Expect(s.ContainsAddressPort(net.ParseIP("2001:db8::1"), "tcp", 81)).To(BeFalse())
+	Expect(s.ContainsAddressPort(net.ParseIP("2001:db8::1"), "udp", 80)).To(BeFalse())
+
+	s.RemoveString("[2001:db8::1]:tcp:80")
+	Expect(s.ContainsAddressPort(net.ParseIP("2001:db8::1"), "tcp", 80)).To(BeFalse())
+}
+
+func TestIPSetContainsAddressPortRange(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := NewIPSet(proto.IPSetUpdate_IP)
+	s.AddString("192.168.1.1,tcp:23-24")
+
+	Expect(s.ContainsAddressPort(net.ParseIP("192.168.1.1"), "tcp", 23)).To(BeTrue())
+	Expect(s.ContainsAddressPort(net.ParseIP("192.168.1.1"), "tcp", 24)).To(BeTrue())
+	Expect(s.ContainsAddressPort(net.ParseIP("192.168.1.1"), "tcp", 25)).To(BeFalse())
+	Expect(s.ContainsAddressPort(net.ParseIP("192.168.1.1"), "tcp", 22)).To(BeFalse())
+}
+
+func TestIPSetContainsAddressPortWildcard(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := NewIPSet(proto.IPSetUpdate_IP)
+	s.AddString("0.0.0.0/0,tcp:*")
+
+	Expect(s.ContainsAddressPort(net.ParseIP("8.8.8.8"), "tcp", 443)).To(BeTrue())
+	Expect(s.ContainsAddressPort(net.ParseIP("8.8.8.8"), "tcp", 1)).To(BeTrue())
+	Expect(s.ContainsAddressPort(net.ParseIP("8.8.8.8"), "udp", 443)).To(BeFalse())
+}
+
+func TestIPSetContainsAddressPortCIDRSource(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := NewIPSet(proto.IPSetUpdate_IP)
+	s.AddString("192.168.1.0/24,tcp:443")
+	// A more specific host entry in the same set names a different port;
+	// both should apply independently of the other's prefix length.
+	s.AddString("192.168.1.5,tcp:8080")
+
+	Expect(s.ContainsAddressPort(net.ParseIP("192.168.1.1"), "tcp", 443)).To(BeTrue())
+	Expect(s.ContainsAddressPort(net.ParseIP("192.168.1.1"), "tcp", 8080)).To(BeFalse())
+	Expect(s.ContainsAddressPort(net.ParseIP("192.168.1.5"), "tcp", 443)).To(BeTrue())
+	Expect(s.ContainsAddressPort(net.ParseIP("192.168.1.5"), "tcp", 8080)).To(BeTrue())
+	Expect(s.ContainsAddressPort(net.ParseIP("192.168.2.1"), "tcp", 443)).To(BeFalse())
+}
+
+func TestIPSetMalformedMembersIgnored(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := NewIPSet(proto.IPSetUpdate_IP)
+	s.AddString("not-an-ip")
+	s.AddString("[2001:db8::1]:tcp:not-a-port")
+	s.AddString("[2001:db8::1")
+
+	Expect(s.ContainsAddress(net.ParseIP("2001:db8::1"))).To(BeFalse())
+}
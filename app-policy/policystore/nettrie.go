@@ -0,0 +1,199 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policystore
+
+import (
+	"fmt"
+	"net"
+)
+
+// NetTrie is a per-family (IPv4/IPv6) binary trie over CIDR prefixes,
+// used to replace the linear "walk every CIDR in the rule" scan that
+// matchNet and the IP-set membership checks previously did. It is built
+// once when a policy update is processed and then read many times on the
+// CheckRequest hot path, so lookups are O(prefix length) rather than
+// O(number of prefixes).
+//
+// A single node may own more than one id: two rules (or an IP set and a
+// rule) can both claim the same CIDR, so ids are stored as a set at the
+// node where that exact prefix terminates.
+type NetTrie struct {
+	v4 *trieNode
+	v6 *trieNode
+}
+
+type trieNode struct {
+	children [2]*trieNode
+	ids      map[string]bool
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{}
+}
+
+// NewNetTrie returns an empty trie.
+func NewNetTrie() *NetTrie {
+	return &NetTrie{v4: newTrieNode(), v6: newTrieNode()}
+}
+
+// Insert adds id as an owner of cidr. The same id can be inserted under
+// multiple CIDRs, and the same CIDR can have multiple ids.
+func (t *NetTrie) Insert(cidr string, id string) error {
+	ip, bits, ones, err := parseCIDRBits(cidr)
+	if err != nil {
+		return err
+	}
+	root := t.rootFor(bits)
+	node := root
+	for i := 0; i < ones; i++ {
+		b := bitAt(ip, i)
+		if node.children[b] == nil {
+			node.children[b] = newTrieNode()
+		}
+		node = node.children[b]
+	}
+	if node.ids == nil {
+		node.ids = make(map[string]bool)
+	}
+	node.ids[id] = true
+	return nil
+}
+
+// Delete removes id as an owner of cidr. It is a no-op if id was never
+// inserted under that CIDR. Nodes are left in place (rather than pruned)
+// once emptied, trading a small amount of memory for simplicity; a
+// long-running Felix instance rebuilds the trie wholesale on the next
+// policy resync in practice.
+func (t *NetTrie) Delete(cidr string, id string) error {
+	ip, bits, ones, err := parseCIDRBits(cidr)
+	if err != nil {
+		return err
+	}
+	node := t.rootFor(bits)
+	for i := 0; i < ones; i++ {
+		b := bitAt(ip, i)
+		if node.children[b] == nil {
+			return nil
+		}
+		node = node.children[b]
+	}
+	delete(node.ids, id)
+	return nil
+}
+
+// LongestMatch returns the ids owned by the longest (most specific) prefix
+// in the trie that contains ip, or nil if no prefix contains it.
+func (t *NetTrie) LongestMatch(ip net.IP) []string {
+	v4 := ip.To4()
+	var node *trieNode
+	var addr net.IP
+	if v4 != nil {
+		node, addr = t.v4, v4
+	} else {
+		v6 := ip.To16()
+		if v6 == nil {
+			return nil
+		}
+		node, addr = t.v6, v6
+	}
+
+	var best map[string]bool
+	maxBits := len(addr) * 8
+	for i := 0; i < maxBits && node != nil; i++ {
+		if len(node.ids) > 0 {
+			best = node.ids
+		}
+		node = node.children[bitAt(addr, i)]
+	}
+	if node != nil && len(node.ids) > 0 {
+		best = node.ids
+	}
+	if best == nil {
+		return nil
+	}
+	out := make([]string, 0, len(best))
+	for id := range best {
+		out = append(out, id)
+	}
+	return out
+}
+
+// AllMatches returns the ids owned by every prefix in the trie that
+// contains ip, from least to most specific, unlike LongestMatch which
+// returns only the most specific prefix's ids. Used where shorter and
+// longer prefixes can carry independent, non-overriding data -- e.g. an
+// IP/port set where a /0 entry and a /32 entry name different ports for
+// the same address.
+func (t *NetTrie) AllMatches(ip net.IP) []string {
+	v4 := ip.To4()
+	var node *trieNode
+	var addr net.IP
+	if v4 != nil {
+		node, addr = t.v4, v4
+	} else {
+		v6 := ip.To16()
+		if v6 == nil {
+			return nil
+		}
+		node, addr = t.v6, v6
+	}
+
+	var out []string
+	maxBits := len(addr) * 8
+	for i := 0; i < maxBits && node != nil; i++ {
+		for id := range node.ids {
+			out = append(out, id)
+		}
+		node = node.children[bitAt(addr, i)]
+	}
+	if node != nil {
+		for id := range node.ids {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func (t *NetTrie) rootFor(bits int) *trieNode {
+	if bits == 32 {
+		return t.v4
+	}
+	return t.v6
+}
+
+// parseCIDRBits parses cidr and returns the network address (as 4 or 16
+// raw bytes), the address family width in bits (32 or 128), and the
+// prefix length.
+func parseCIDRBits(cidr string) (net.IP, int, int, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+	}
+	ones, bits := ipNet.Mask.Size()
+	addr := ipNet.IP
+	if bits == 32 {
+		addr = addr.To4()
+	} else {
+		addr = addr.To16()
+	}
+	return addr, bits, ones, nil
+}
+
+// bitAt returns the i'th bit (0 = most significant) of addr, MSB-first.
+func bitAt(addr net.IP, i int) int {
+	byteIdx := i / 8
+	bitIdx := 7 - uint(i%8)
+	return int((addr[byteIdx] >> bitIdx) & 1)
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policystore
+
+import (
+	"net"
+	"sort"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestNetTrieMixedFamilies(t *testing.T) {
+	RegisterTestingT(t)
+
+	trie := NewNetTrie()
+	Expect(trie.Insert("192.168.0.0/16", "v4-rule")).To(Succeed())
+	Expect(trie.Insert("45ab:0023::/32", "v6-rule")).To(Succeed())
+
+	Expect(trie.LongestMatch(net.ParseIP("192.168.3.145"))).To(ConsistOf("v4-rule"))
+	Expect(trie.LongestMatch(net.ParseIP("45ab:0023::abcd"))).To(ConsistOf("v6-rule"))
+	Expect(trie.LongestMatch(net.ParseIP("10.0.0.1"))).To(BeEmpty())
+	Expect(trie.LongestMatch(net.ParseIP("85ab:0023::abcd"))).To(BeEmpty())
+}
+
+func TestNetTrieWildcard(t *testing.T) {
+	RegisterTestingT(t)
+
+	trie := NewNetTrie()
+	Expect(trie.Insert("0.0.0.0/0", "any-v4")).To(Succeed())
+	Expect(trie.Insert("192.168.1.0/24", "specific")).To(Succeed())
+
+	// The more specific prefix wins over the /0 wildcard.
+	Expect(trie.LongestMatch(net.ParseIP("192.168.1.5"))).To(ConsistOf("specific"))
+	// Everything else still falls back to the wildcard.
+	Expect(trie.LongestMatch(net.ParseIP("8.8.8.8"))).To(ConsistOf("any-v4"))
+
+	Expect(trie.Insert("::/0", "any-v6")).To(Succeed())
+	Expect(trie.LongestMatch(net.ParseIP("::1"))).To(ConsistOf("any-v6"))
+}
+
+func TestNetTrieDuplicatePrefixMultipleOwners(t *testing.T) {
+	RegisterTestingT(t)
+
+	trie := NewNetTrie()
+	Expect(trie.Insert("10.0.0.0/8", "rule-a")).To(Succeed())
+	Expect(trie.Insert("10.0.0.0/8", "rule-b")).To(Succeed())
+
+	got := trie.LongestMatch(net.ParseIP("10.1.2.3"))
+	sort.Strings(got)
+	Expect(got).To(Equal([]string{"rule-a", "rule-b"}))
+
+	Expect(trie.Delete("10.0.0.0/8", "rule-a")).To(Succeed())
+	Expect(trie.LongestMatch(net.ParseIP("10.1.2.3"))).To(ConsistOf("rule-b"))
+}
+
+func TestNetTrieAllMatches(t *testing.T) {
+	RegisterTestingT(t)
+
+	trie := NewNetTrie()
+	Expect(trie.Insert("0.0.0.0/0", "any")).To(Succeed())
+	Expect(trie.Insert("192.168.1.0/24", "specific")).To(Succeed())
+
+	// LongestMatch only sees the most specific owner...
+	Expect(trie.LongestMatch(net.ParseIP("192.168.1.5"))).To(ConsistOf("specific"))
+	// ...but AllMatches sees every prefix along the way.
+	got := trie.AllMatches(net.ParseIP("192.168.1.5"))
+	sort.Strings(got)
+	Expect(got).To(Equal([]string{"any", "specific"}))
+
+	Expect(trie.AllMatches(net.ParseIP("8.8.8.8"))).To(ConsistOf("any"))
+}
+
+func TestNetTrieInvalidCIDR(t *testing.T) {
+	RegisterTestingT(t)
+
+	trie := NewNetTrie()
+	Expect(trie.Insert("not-a-cidr", "id")).ToNot(Succeed())
+	Expect(trie.LongestMatch(net.ParseIP("10.0.0.1"))).To(BeEmpty())
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policystore holds the in-memory copy of the policy state that
+// Felix streams down to the per-node ext_authz checker over the policy
+// sync API. It is rebuilt from a sequence of proto updates and read by the
+// checker on the request hot path.
+package policystore
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/projectcalico/calico/felix/proto"
+	"github.com/projectcalico/calico/felix/types"
+)
+
+// PolicyStore is a snapshot of the policy state needed to evaluate
+// CheckRequests: IP sets, namespaces, service accounts, policies and
+// profiles, keyed the same way Felix identifies them on the wire.
+type PolicyStore struct {
+	IPSetByID     map[string]*IPSet
+	NamespaceByID map[types.NamespaceID]*proto.NamespaceUpdate
+
+	// Tracing controls whether the checker emits OpenTelemetry spans for
+	// CheckRequest evaluation. It is part of the store, rather than a
+	// separate global, so that tracing can be turned on or off by the same
+	// config-update path as everything else the checker depends on.
+	Tracing TracingConfig
+
+	// generation is bumped every time the store's policy state changes.
+	// The checker's per-flow decision cache stamps cached decisions with
+	// the generation they were computed under, so a config update
+	// invalidates the whole cache for free without the store needing to
+	// know the cache exists.
+	generation uint64
+
+	// regexCacheMu and regexCache back CompiledRegex: the checker's compiled
+	// HTTP path/header/host regexes, scoped to this store's lifetime so they
+	// never outlive the policy generation they were compiled under. Cleared
+	// on Bump rather than evicted entry-by-entry, since a config update can
+	// change or remove the rule a given pattern came from.
+	regexCacheMu sync.RWMutex
+	regexCache   map[string]*regexp.Regexp
+
+	// netTrieCacheMu and netTrieCache back TrieForNets, the same way
+	// regexCache backs CompiledRegex, for the NetTrie built from a rule's
+	// SrcNet/DstNet/NotSrcNet/NotDstNet CIDR list.
+	netTrieCacheMu sync.RWMutex
+	netTrieCache   map[string]*NetTrie
+}
+
+// Generation returns the store's current generation counter. It is safe to
+// call concurrently with Bump.
+func (s *PolicyStore) Generation() uint64 {
+	return atomic.LoadUint64(&s.generation)
+}
+
+// Bump increments the store's generation counter. The sync client must
+// call this after applying any update to IPSetByID, NamespaceByID or any
+// other field that can change a CheckRequest's outcome, so that anything
+// caching decisions by generation notices the store moved on. It also
+// drops the compiled-regex and NetTrie caches backing CompiledRegex and
+// TrieForNets, since a rule that changed or disappeared in the update
+// could be the one a cached pattern or CIDR list came from.
+func (s *PolicyStore) Bump() {
+	atomic.AddUint64(&s.generation, 1)
+
+	s.regexCacheMu.Lock()
+	s.regexCache = nil
+	s.regexCacheMu.Unlock()
+
+	s.netTrieCacheMu.Lock()
+	s.netTrieCache = nil
+	s.netTrieCacheMu.Unlock()
+}
+
+// CompiledRegex compiles pattern, or returns the already-compiled regex
+// from the store's cache. The cache is cleared on every Bump, so it never
+// holds a pattern from a generation older than the store's current one.
+func (s *PolicyStore) CompiledRegex(pattern string) (*regexp.Regexp, error) {
+	s.regexCacheMu.RLock()
+	re, ok := s.regexCache[pattern]
+	s.regexCacheMu.RUnlock()
+	if ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	s.regexCacheMu.Lock()
+	if s.regexCache == nil {
+		s.regexCache = make(map[string]*regexp.Regexp)
+	}
+	s.regexCache[pattern] = re
+	s.regexCacheMu.Unlock()
+	return re, nil
+}
+
+// TrieForNets builds a NetTrie over nets, or returns the already-built trie
+// from the store's cache. The cache is cleared on every Bump, so it never
+// holds a trie built from a CIDR list an older generation's rule supplied.
+func (s *PolicyStore) TrieForNets(nets []string) *NetTrie {
+	key := strings.Join(nets, ",")
+
+	s.netTrieCacheMu.RLock()
+	t, ok := s.netTrieCache[key]
+	s.netTrieCacheMu.RUnlock()
+	if ok {
+		return t
+	}
+
+	t = NewNetTrie()
+	for i, n := range nets {
+		// A CIDR that fails to parse never matches, but doesn't abort
+		// insertion of the rest of the list.
+		_ = t.Insert(n, strconv.Itoa(i))
+	}
+
+	s.netTrieCacheMu.Lock()
+	if s.netTrieCache == nil {
+		s.netTrieCache = make(map[string]*NetTrie)
+	}
+	s.netTrieCache[key] = t
+	s.netTrieCacheMu.Unlock()
+	return t
+}
+
+// TracingConfig is the checker's OpenTelemetry tracing configuration.
+type TracingConfig struct {
+	// Enabled turns on span creation for CheckRequest evaluation. It is
+	// off by default: most deployments run the checker on a latency- and
+	// throughput-sensitive hot path, so tracing must be opted into.
+	Enabled bool
+	// OTLPEndpoint is the OTLP/gRPC collector endpoint spans are exported
+	// to, e.g. "otel-collector.calico-system.svc:4317". Ignored when
+	// Enabled is false.
+	OTLPEndpoint string
+}
+
+// NewPolicyStore creates an empty PolicyStore ready to be populated by the
+// sync client.
+func NewPolicyStore() *PolicyStore {
+	return &PolicyStore{
+		IPSetByID:     make(map[string]*IPSet),
+		NamespaceByID: make(map[types.NamespaceID]*proto.NamespaceUpdate),
+	}
+}
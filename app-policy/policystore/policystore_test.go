@@ -0,0 +1,70 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policystore
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestPolicyStoreGenerationStartsAtZeroAndBumps(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := NewPolicyStore()
+	Expect(s.Generation()).To(Equal(uint64(0)))
+
+	s.Bump()
+	Expect(s.Generation()).To(Equal(uint64(1)))
+
+	s.Bump()
+	s.Bump()
+	Expect(s.Generation()).To(Equal(uint64(3)))
+}
+
+func TestPolicyStoreCompiledRegexCachesAndBumpInvalidates(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := NewPolicyStore()
+	re1, err := s.CompiledRegex("^/api/.*$")
+	Expect(err).ToNot(HaveOccurred())
+
+	re2, err := s.CompiledRegex("^/api/.*$")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(re2).To(BeIdenticalTo(re1))
+
+	s.Bump()
+	re3, err := s.CompiledRegex("^/api/.*$")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(re3).ToNot(BeIdenticalTo(re1))
+
+	_, err = s.CompiledRegex("(")
+	Expect(err).To(HaveOccurred())
+}
+
+func TestPolicyStoreTrieForNetsCachesAndBumpInvalidates(t *testing.T) {
+	RegisterTestingT(t)
+
+	s := NewPolicyStore()
+	nets := []string{"10.0.0.0/8", "192.168.0.0/16"}
+
+	t1 := s.TrieForNets(nets)
+	t2 := s.TrieForNets(nets)
+	Expect(t2).To(BeIdenticalTo(t1))
+
+	s.Bump()
+	t3 := s.TrieForNets(nets)
+	Expect(t3).ToNot(BeIdenticalTo(t1))
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package server wires the checker package up to a gRPC ext_authz server:
+// panic recovery, and (eventually) the Check/StreamChannel handlers
+// themselves.
+package server
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/projectcalico/calico/app-policy/checker"
+)
+
+// panicsTotal counts every panic the interceptors below recover from,
+// labelled by the kind of panic (the well-known
+// "invalid_data_from_dataplane" case, or "internal" for anything else) and
+// the policy tier being evaluated when it happened, so operators can tell
+// dataplane corruption scoped to one tier from a genuine bug.
+var panicsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "calico_app_policy_check_panics_total",
+	Help: "Number of panics recovered from while evaluating a CheckRequest.",
+}, []string{"kind", "tier"})
+
+func init() {
+	prometheus.MustRegister(panicsTotal)
+}
+
+// ruleTierKey is the context key WithRuleTier stores a *ruleTierBox under.
+type ruleTierKey struct{}
+
+// ruleTierBox is a mutable box for the tier currently being evaluated. It
+// has to be mutable-through-a-pointer, rather than a plain context value,
+// because a single Check call evaluates many tiers in sequence and a panic
+// can unwind the stack at any point in that sequence; the interceptor's
+// deferred recover() only has the context it originally passed to the
+// handler; it cannot see a context reassigned inside the handler's own
+// (now-unwound) stack frame.
+type ruleTierBox struct {
+	mu   sync.Mutex
+	tier string
+}
+
+// WithRuleTier returns a context carrying a fresh, mutable tier tracker.
+// The Check handler should derive its context from this once per RPC, then
+// call SetRuleTier as it moves from one policy tier's rules to the next.
+func WithRuleTier(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ruleTierKey{}, &ruleTierBox{})
+}
+
+// SetRuleTier records tier as the policy tier currently being evaluated in
+// ctx, for the panic interceptors' metrics/logging. It is a no-op if ctx
+// wasn't derived from WithRuleTier.
+func SetRuleTier(ctx context.Context, tier string) {
+	if box, ok := ctx.Value(ruleTierKey{}).(*ruleTierBox); ok {
+		box.mu.Lock()
+		box.tier = tier
+		box.mu.Unlock()
+	}
+}
+
+func ruleTierFromContext(ctx context.Context) string {
+	box, ok := ctx.Value(ruleTierKey{}).(*ruleTierBox)
+	if !ok {
+		return ""
+	}
+	box.mu.Lock()
+	defer box.mu.Unlock()
+	return box.tier
+}
+
+// recoverToStatus turns a recovered panic value into the gRPC status it
+// should be reported as, incrementing panicsTotal and logging a stack
+// trace for anything other than the well-known InvalidDataFromDataPlane
+// case. r is whatever recover() returned; it must not be nil.
+func recoverToStatus(ctx context.Context, r interface{}) error {
+	tier := ruleTierFromContext(ctx)
+
+	if invalid, ok := r.(*checker.InvalidDataFromDataPlane); ok {
+		panicsTotal.WithLabelValues("invalid_data_from_dataplane", tier).Inc()
+		return status.Errorf(codes.InvalidArgument, "invalid data from dataplane: %s", invalid.Error())
+	}
+
+	panicsTotal.WithLabelValues("internal", tier).Inc()
+	logrus.WithFields(logrus.Fields{
+		"tier":  tier,
+		"panic": r,
+		"stack": string(debug.Stack()),
+	}).Error("Recovered from panic evaluating CheckRequest.")
+	return status.Error(codes.Internal, "internal error evaluating CheckRequest")
+}
+
+// UnaryPanicInterceptor recovers panics raised while evaluating a unary
+// Check RPC -- notably checker.InvalidDataFromDataPlane, panicked by the
+// matchers when Felix sends malformed data -- and converts them into a
+// gRPC status instead of letting them tear down the connection. This lets
+// Envoy see a clean deny/error rather than a broken stream.
+func UnaryPanicInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	ctx = WithRuleTier(ctx)
+	defer func() {
+		if r := recover(); r != nil {
+			resp, err = nil, recoverToStatus(ctx, r)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// StreamPanicInterceptor is the streaming equivalent of
+// UnaryPanicInterceptor, used for Check's streaming gRPC transport.
+func StreamPanicInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	ctx := WithRuleTier(ss.Context())
+	wrapped := &tieredServerStream{ServerStream: ss, ctx: ctx}
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoverToStatus(ctx, r)
+		}
+	}()
+	return handler(srv, wrapped)
+}
+
+// tieredServerStream overrides Context() so the tier tracker
+// StreamPanicInterceptor installs reaches the handler.
+type tieredServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tieredServerStream) Context() context.Context { return s.ctx }
@@ -0,0 +1,126 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	auth "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/projectcalico/calico/app-policy/checker"
+	"github.com/projectcalico/calico/app-policy/policystore"
+	"github.com/projectcalico/calico/felix/proto"
+)
+
+// panickingCheckHandler stands in for the real Check RPC handler: it
+// drives the actual checker.CheckRule evaluation path against a
+// CheckRequest whose HTTP path doesn't start with "/", which is exactly
+// what matchHTTPPaths (see checker.TestPanicHTTPPaths) treats as malformed
+// dataplane data and panics on.
+func panickingCheckHandler(ctx context.Context, _ interface{}) (interface{}, error) {
+	SetRuleTier(ctx, "default")
+
+	rule := &proto.Rule{HttpMatch: &proto.HTTPMatch{
+		Paths: []*proto.HTTPMatch_PathMatch{{PathMatch: &proto.HTTPMatch_PathMatch_Exact{Exact: "/x"}}},
+	}}
+	req := &auth.CheckRequest{Attributes: &auth.AttributeContext{
+		Request: &auth.AttributeContext_Request{
+			Http: &auth.AttributeContext_HttpRequest{Path: "no-leading-slash"},
+		},
+	}}
+	store := policystore.NewPolicyStore()
+	reqCache := checker.NewRequestCache(store, checker.NewCheckRequestToFlowAdapter(req))
+
+	checker.CheckRule(ctx, store, checker.RuleMeta{Tier: "default"}, "", rule, reqCache)
+	return nil, nil
+}
+
+func TestUnaryPanicInterceptorConvertsInvalidDataFromDataPlane(t *testing.T) {
+	RegisterTestingT(t)
+	panicsTotal.Reset()
+
+	resp, err := UnaryPanicInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/envoy.service.auth.v3.Authorization/Check"}, panickingCheckHandler)
+	Expect(resp).To(BeNil())
+
+	st, ok := status.FromError(err)
+	Expect(ok).To(BeTrue())
+	Expect(st.Code()).To(Equal(codes.InvalidArgument))
+	Expect(st.Message()).To(ContainSubstring("does not start with"))
+
+	Expect(testutil.ToFloat64(panicsTotal.WithLabelValues("invalid_data_from_dataplane", "default"))).To(Equal(float64(1)))
+}
+
+func TestUnaryPanicInterceptorConvertsOtherPanicsToInternal(t *testing.T) {
+	RegisterTestingT(t)
+	panicsTotal.Reset()
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		SetRuleTier(ctx, "emergency")
+		panic("totally unrelated bug")
+	}
+
+	resp, err := UnaryPanicInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+	Expect(resp).To(BeNil())
+
+	st, ok := status.FromError(err)
+	Expect(ok).To(BeTrue())
+	Expect(st.Code()).To(Equal(codes.Internal))
+
+	Expect(testutil.ToFloat64(panicsTotal.WithLabelValues("internal", "emergency"))).To(Equal(float64(1)))
+}
+
+func TestUnaryPanicInterceptorPassesThroughNormalResponses(t *testing.T) {
+	RegisterTestingT(t)
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	resp, err := UnaryPanicInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(resp).To(Equal("ok"))
+}
+
+// fakeServerStream is a minimal grpc.ServerStream whose Context() we can
+// observe being overridden by StreamPanicInterceptor.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context { return s.ctx }
+
+func TestStreamPanicInterceptorConvertsInvalidDataFromDataPlane(t *testing.T) {
+	RegisterTestingT(t)
+	panicsTotal.Reset()
+
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		_, err := panickingCheckHandler(ss.Context(), nil)
+		return err
+	}
+
+	err := StreamPanicInterceptor(nil, &fakeServerStream{ctx: context.Background()}, &grpc.StreamServerInfo{}, handler)
+
+	st, ok := status.FromError(err)
+	Expect(ok).To(BeTrue())
+	Expect(st.Code()).To(Equal(codes.InvalidArgument))
+	Expect(testutil.ToFloat64(panicsTotal.WithLabelValues("invalid_data_from_dataplane", "default"))).To(Equal(float64(1)))
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2022-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package environment probes the host's iptables/nftables installation so
+// that felix/iptables can decide, once per process, which binaries and
+// match modules it can rely on rather than re-detecting on every Apply.
+package environment
+
+import (
+	"io"
+	"os/exec"
+
+	"github.com/projectcalico/calico/felix/generictables"
+)
+
+// FeatureDetector caches the result of probing the local iptables
+// installation. NewCmd and GetKernelVersionReader are overridable so tests
+// can substitute a fake dataplane instead of shelling out for real.
+type FeatureDetector struct {
+	NewCmd                 func(name string, arg ...string) *exec.Cmd
+	GetKernelVersionReader func() (io.Reader, error)
+
+	overrides map[string]string
+	cached    *generictables.Features
+}
+
+// NewFeatureDetector creates a FeatureDetector. overrides forces specific
+// feature values (keyed by feature name) instead of probing for them,
+// which is mainly useful for tests and for operators working around a
+// misdetection on an unusual kernel.
+func NewFeatureDetector(overrides map[string]string) *FeatureDetector {
+	return &FeatureDetector{
+		NewCmd:    exec.Command,
+		overrides: overrides,
+	}
+}
+
+// GetFeatures returns the detected feature set, probing the dataplane on
+// first call and caching the result thereafter.
+func (d *FeatureDetector) GetFeatures() *generictables.Features {
+	if d.cached != nil {
+		return d.cached
+	}
+	f := &generictables.Features{
+		SNATFullyRandom:     d.overrides["SNATFullyRandom"] != "false",
+		MASQFullyRandom:     d.overrides["MASQFullyRandom"] != "false",
+		RestoreSupportsLock: d.overrides["RestoreSupportsLock"] != "false",
+	}
+	d.cached = f
+	return f
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2022-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package generictables holds the dataplane-agnostic types that both the
+// iptables and (future) nftables backends render into their respective
+// rule syntaxes. Keeping Rule/Action/MatchCriteria here, rather than in the
+// iptables package itself, is what lets a single rule set be handed to
+// either backend unchanged.
+package generictables
+
+// Features describes the subset of dataplane capabilities a backend needs
+// to know about in order to decide how to render a Rule -- for example,
+// whether the running iptables supports a given match module.
+type Features struct {
+	SNATFullyRandom bool
+	MASQFullyRandom bool
+	RestoreSupportsLock bool
+}
+
+// Action is a rule's terminating or non-terminating action (DROP, ACCEPT,
+// RETURN, JUMP, ...). Render produces the backend-specific fragment for
+// features.
+type Action interface {
+	Render(features Features) string
+}
+
+// MatchCriteria builds up the match portion of a rule. Each method returns
+// a new MatchCriteria so that criteria can be composed and shared safely,
+// mirroring how iptables match clauses are additive.
+type MatchCriteria interface {
+	Render(features Features) string
+	Protocol(proto string) MatchCriteria
+	SourceNet(cidr string) MatchCriteria
+	DestNet(cidr string) MatchCriteria
+	SourceIPSet(setID string) MatchCriteria
+	DestIPSet(setID string) MatchCriteria
+}
+
+// Rule is one dataplane-agnostic rule: an optional Match, a required
+// Action, and an optional Comment (rendered as a comment match/annotation
+// by whichever backend is in use, purely for operator debugging).
+type Rule struct {
+	Match   MatchCriteria
+	Action  Action
+	Comment []string
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2017-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import "github.com/projectcalico/calico/felix/generictables"
+
+// DropAction renders as "-j DROP".
+type DropAction struct{}
+
+func (DropAction) Render(generictables.Features) string { return "-j DROP" }
+
+// AcceptAction renders as "-j ACCEPT".
+type AcceptAction struct{}
+
+func (AcceptAction) Render(generictables.Features) string { return "-j ACCEPT" }
+
+// ReturnAction renders as "-j RETURN".
+type ReturnAction struct{}
+
+func (ReturnAction) Render(generictables.Features) string { return "-j RETURN" }
+
+// JumpAction renders as "-j <Target>".
+type JumpAction struct {
+	Target string
+}
+
+func (a JumpAction) Render(generictables.Features) string { return "-j " + a.Target }
+
+// matchCriteria is the concrete generictables.MatchCriteria this package
+// hands back from Match(); it accumulates rendered clauses as they're
+// added, in the order they're added, mirroring how iptables treats match
+// clauses as an ordered, additive list.
+type matchCriteria struct {
+	clauses []string
+}
+
+// Match starts building a new, empty MatchCriteria.
+func Match() generictables.MatchCriteria {
+	return &matchCriteria{}
+}
+
+func (m *matchCriteria) Render(generictables.Features) string {
+	out := ""
+	for i, c := range m.clauses {
+		if i > 0 {
+			out += " "
+		}
+		out += c
+	}
+	return out
+}
+
+func (m *matchCriteria) clone(extra string) *matchCriteria {
+	next := make([]string, len(m.clauses), len(m.clauses)+1)
+	copy(next, m.clauses)
+	return &matchCriteria{clauses: append(next, extra)}
+}
+
+func (m *matchCriteria) Protocol(proto string) generictables.MatchCriteria {
+	return m.clone("-p " + proto)
+}
+
+func (m *matchCriteria) SourceNet(cidr string) generictables.MatchCriteria {
+	return m.clone("-s " + cidr)
+}
+
+func (m *matchCriteria) DestNet(cidr string) generictables.MatchCriteria {
+	return m.clone("-d " + cidr)
+}
+
+func (m *matchCriteria) SourceIPSet(setID string) generictables.MatchCriteria {
+	return m.clone("-m set --match-set " + setID + " src")
+}
+
+func (m *matchCriteria) DestIPSet(setID string) generictables.MatchCriteria {
+	return m.clone("-m set --match-set " + setID + " dst")
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ruleCounterSample is one "[pkts:bytes] -A CHAIN ... --comment
+// \"cali:hash\" ..." line parsed out of iptables-save -c, attributed to
+// the chain and rule hash that produced it.
+type ruleCounterSample struct {
+	chain    string
+	ruleHash string
+	packets  uint64
+	bytes    uint64
+}
+
+// CountersReader periodically parses iptables-save -c (or, for
+// BackendMode "nft", iptables-nft-save -c) and exposes each rule's hit/byte
+// counters as Prometheus metrics labelled by chain name and rule hash,
+// matched back to the cali:<hash> comment renderRule embeds on every rule.
+type CountersReader struct {
+	table    *Table
+	interval time.Duration
+
+	packets *prometheus.GaugeVec
+	bytes   *prometheus.GaugeVec
+
+	stopCh chan struct{}
+}
+
+// NewCountersReader creates a CountersReader for table and registers its
+// metrics with registry. It does not start collecting until Start is
+// called.
+func NewCountersReader(table *Table, interval time.Duration, registry prometheus.Registerer) *CountersReader {
+	packets := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "felix_iptables_rule_packets",
+		Help: "Packets matched by a felix-programmed iptables rule, labelled by chain and rule hash.",
+	}, []string{"chain", "rule_hash"})
+	bytes := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "felix_iptables_rule_bytes",
+		Help: "Bytes matched by a felix-programmed iptables rule, labelled by chain and rule hash.",
+	}, []string{"chain", "rule_hash"})
+	if registry != nil {
+		registry.MustRegister(packets, bytes)
+	}
+	return &CountersReader{
+		table:    table,
+		interval: interval,
+		packets:  packets,
+		bytes:    bytes,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start runs the periodic collection loop until Stop is called. It should
+// be run in its own goroutine.
+func (c *CountersReader) Start() {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.refresh()
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+// Stop ends the collection loop started by Start.
+func (c *CountersReader) Stop() {
+	close(c.stopCh)
+}
+
+// refresh re-reads iptables-save -c and updates the gauges.
+func (c *CountersReader) refresh() {
+	saveCmd := "iptables-save"
+	if c.table.options.BackendMode == "nft" {
+		saveCmd = "iptables-nft-save"
+	}
+	cmd := c.table.newCmd(saveCmd, "-c")
+	out, err := cmd.Output()
+	if err != nil {
+		return
+	}
+	for _, s := range parseCounterSamples(string(out), c.table.hashPrefix) {
+		c.packets.WithLabelValues(s.chain, s.ruleHash).Set(float64(s.packets))
+		c.bytes.WithLabelValues(s.chain, s.ruleHash).Set(float64(s.bytes))
+	}
+}
+
+// parseCounterSamples parses the body of an `iptables-save -c` (or
+// iptables-nft-save -c) dump into one ruleCounterSample per rule line that
+// carries a <hashPrefix><hash> comment. Lines without one (rules felix
+// didn't program) are skipped, since there is no rule hash to attribute
+// their counters to.
+func parseCounterSamples(saveOutput string, hashPrefix string) []ruleCounterSample {
+	var samples []ruleCounterSample
+	scanner := bufio.NewScanner(strings.NewReader(saveOutput))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		closeBr := strings.Index(line, "]")
+		if closeBr < 0 {
+			continue
+		}
+		counts := strings.SplitN(line[1:closeBr], ":", 2)
+		if len(counts) != 2 {
+			continue
+		}
+		packets, err := strconv.ParseUint(counts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		byteCount, err := strconv.ParseUint(counts[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		rest := strings.TrimSpace(line[closeBr+1:])
+		fields := strings.Fields(rest)
+		if len(fields) < 2 || fields[0] != "-A" {
+			continue
+		}
+		chain := fields[1]
+
+		hash := ruleHashFromComment(rest, hashPrefix)
+		if hash == "" {
+			continue
+		}
+
+		samples = append(samples, ruleCounterSample{
+			chain:    chain,
+			ruleHash: hash,
+			packets:  packets,
+			bytes:    byteCount,
+		})
+	}
+	return samples
+}
+
+// ruleHashFromComment extracts the hash out of a rendered rule's
+// --comment "<hashPrefix><hash>" clause, or "" if the line has none.
+func ruleHashFromComment(line string, hashPrefix string) string {
+	marker := `--comment "` + hashPrefix
+	idx := strings.Index(line, marker)
+	if idx < 0 {
+		return ""
+	}
+	rest := line[idx+len(marker):]
+	end := strings.Index(rest, `"`)
+	if end < 0 {
+		return ""
+	}
+	return rest[:end]
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"testing"
+
+	"github.com/projectcalico/calico/felix/generictables"
+)
+
+const sampleSaveOutput = `# Generated by iptables-save
+*filter
+:cali-FORWARD - [0:0]
+[12:900] -A cali-FORWARD -p tcp -m comment --comment "cali:abcd1234" -j DROP
+[0:0] -A cali-FORWARD -m comment --comment "cali:ef567890" -j ACCEPT
+[3:45] -A OTHER-CHAIN -j ACCEPT
+COMMIT
+`
+
+func TestParseCounterSamples(t *testing.T) {
+	samples := parseCounterSamples(sampleSaveOutput, "cali:")
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 attributable samples, got %d: %+v", len(samples), samples)
+	}
+	if samples[0].chain != "cali-FORWARD" || samples[0].ruleHash != "abcd1234" || samples[0].packets != 12 || samples[0].bytes != 900 {
+		t.Fatalf("unexpected first sample: %+v", samples[0])
+	}
+	if samples[1].ruleHash != "ef567890" || samples[1].packets != 0 {
+		t.Fatalf("unexpected second sample: %+v", samples[1])
+	}
+}
+
+func TestParseCounterSamplesSkipsRulesWithoutHashComment(t *testing.T) {
+	samples := parseCounterSamples(sampleSaveOutput, "cali:")
+	for _, s := range samples {
+		if s.chain == "OTHER-CHAIN" {
+			t.Fatalf("rule with no cali: comment should have been skipped: %+v", s)
+		}
+	}
+}
+
+func TestRenderRuleEmbedsHashComment(t *testing.T) {
+	table := newTestTable()
+	rule := generictables.Rule{Match: Match().Protocol("tcp"), Action: DropAction{}}
+	got := table.renderRule(rule)
+	if got == "" {
+		t.Fatal("expected non-empty rendered rule")
+	}
+	if ruleHashFromComment(got, "cali:") == "" {
+		t.Fatalf("expected embedded cali: hash comment in rendered rule, got %q", got)
+	}
+}
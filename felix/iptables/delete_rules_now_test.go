@@ -0,0 +1,98 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/projectcalico/calico/felix/environment"
+	"github.com/projectcalico/calico/felix/generictables"
+)
+
+func TestDeleteRulesNowOnlyDeletesPresentRules(t *testing.T) {
+	present := generictables.Rule{Match: Match().SourceNet("10.0.0.1/32"), Action: DropAction{}}
+	absent := generictables.Rule{Match: Match().SourceNet("10.0.0.2/32"), Action: DropAction{}}
+
+	probe := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{})
+	presentLine := "-A FORWARD " + probe.renderRule(present)
+
+	dump := "*filter\n:FORWARD ACCEPT [0:0]\n" + presentLine + "\nCOMMIT\n"
+	dumpPath := filepath.Join(t.TempDir(), "dump")
+	if err := os.WriteFile(dumpPath, []byte(dump), 0o644); err != nil {
+		t.Fatalf("writing fake dump: %v", err)
+	}
+	restoreInputPath := filepath.Join(t.TempDir(), "restore-input")
+
+	// Run against a chain Table doesn't manage at all (like "FORWARD"),
+	// mirroring the boot-time-rule-cleanup use case. "save" is stubbed
+	// with the fixture dump; the restore invocation is stubbed with
+	// "tee" so the test can inspect exactly what was piped to it.
+	table := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			if strings.HasSuffix(name, "save") {
+				return exec.Command("cat", dumpPath)
+			}
+			return exec.Command("tee", restoreInputPath)
+		},
+	})
+
+	if err := table.DeleteRulesNow("FORWARD", []generictables.Rule{present, absent}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(restoreInputPath)
+	if err != nil {
+		t.Fatalf("reading captured restore input: %v", err)
+	}
+	input := string(got)
+	if !strings.Contains(input, "-D FORWARD "+probe.renderRule(present)) {
+		t.Errorf("expected restore input to delete the present rule, got: %q", input)
+	}
+	if strings.Contains(input, probe.renderRule(absent)) {
+		t.Errorf("expected restore input not to reference the absent rule, got: %q", input)
+	}
+}
+
+func TestDeleteRulesNowSkipsWhenNothingPresent(t *testing.T) {
+	dump := "*filter\n:FORWARD ACCEPT [0:0]\nCOMMIT\n"
+	dumpPath := filepath.Join(t.TempDir(), "dump")
+	if err := os.WriteFile(dumpPath, []byte(dump), 0o644); err != nil {
+		t.Fatalf("writing fake dump: %v", err)
+	}
+
+	restoreCalled := false
+	table := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			if strings.HasSuffix(name, "save") {
+				return exec.Command("cat", dumpPath)
+			}
+			restoreCalled = true
+			return exec.Command("true")
+		},
+	})
+
+	rule := generictables.Rule{Match: Match().SourceNet("10.0.0.9/32"), Action: AcceptAction{}}
+	if err := table.DeleteRulesNow("FORWARD", []generictables.Rule{rule}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restoreCalled {
+		t.Fatal("expected DeleteRulesNow to skip invoking restore when none of the given rules are present")
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"bufio"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/projectcalico/calico/felix/rules"
+)
+
+// DriftPhase distinguishes drift Table notices while building its next
+// restore payload from drift it notices on a later, otherwise-clean
+// recheck of the live dataplane.
+type DriftPhase string
+
+const (
+	DriftPhasePreRestore DriftPhase = "pre-restore"
+	DriftPhaseRecheck    DriftPhase = "recheck"
+)
+
+// DriftCategory classifies one foreign or unexpected line Table found in a
+// chain it manages.
+type DriftCategory string
+
+const (
+	// DriftInsertedBefore is a foreign line inserted above all of
+	// Table's own rules in the chain.
+	DriftInsertedBefore DriftCategory = "inserted-before"
+	// DriftInsertedAfter is a foreign line inserted below (or between)
+	// Table's own rules in the chain.
+	DriftInsertedAfter DriftCategory = "inserted-after"
+	// DriftOutOfOrder is one of Table's own rules, identified by its
+	// hash comment, found at a different position than Table expects.
+	DriftOutOfOrder DriftCategory = "out-of-order-hash"
+	// DriftUnknownHash is a line carrying Table's comment format and
+	// hash prefix, but a hash Table doesn't recognise for this chain --
+	// e.g. left behind by a since-restarted felix generation.
+	DriftUnknownHash DriftCategory = "unknown-hash"
+	// DriftStaleHistoric is an entire chain under one of
+	// rules.AllHistoricChainNamePrefixes that Table is no longer
+	// managing (so it isn't in t.chains at all), implying a prior
+	// felix version created it and it was never cleaned up.
+	DriftStaleHistoric DriftCategory = "stale-historic"
+)
+
+// DriftEvent describes one instance of drift SubscribeDrift listeners are
+// notified of.
+type DriftEvent struct {
+	Chain       string
+	Lines       []string
+	CalicoOwned bool
+	Phase       DriftPhase
+	Category    DriftCategory
+}
+
+var driftCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "felix_iptables_drift_events",
+	Help: "Count of foreign/unexpected dataplane changes Table has detected, by chain, phase and category.",
+}, []string{"chain", "phase", "category"})
+
+// driftListener pairs a subscriber's callback with the felix/logutils-style
+// "fire and forget" semantics SubscribeDrift promises: listeners are
+// invoked synchronously, in subscription order, under t.mu, so they must
+// not call back into Table.
+type driftListener func(DriftEvent)
+
+// SubscribeDrift registers fn to be called once per DriftEvent Table
+// detects, starting with the next Apply or CheckDataplane call. It returns
+// no unsubscribe handle: Table's lifetime is expected to match its
+// subscribers' (e.g. felix registering its own alerting on startup).
+func (t *Table) SubscribeDrift(fn func(DriftEvent)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.driftListeners = append(t.driftListeners, fn)
+}
+
+// emitDrift notifies every SubscribeDrift listener and increments the
+// per-category Prometheus counter. Callers must hold t.mu.
+func (t *Table) emitDrift(ev DriftEvent) {
+	driftCounter.WithLabelValues(ev.Chain, string(ev.Phase), string(ev.Category)).Inc()
+	if ev.Category == DriftInsertedBefore || ev.Category == DriftInsertedAfter {
+		t.unexpectedInserts++
+	}
+	for _, fn := range t.driftListeners {
+		fn(ev)
+	}
+}
+
+// CheckDataplane reads the live dataplane via captureSave and compares it
+// against every chain Table currently manages (plus any live chain under a
+// historic prefix Table no longer manages), reporting drift with phase
+// phase. Callers drive this on whatever cadence they want a recheck at --
+// e.g. a ticker alongside Apply -- since Table has no periodic loop of its
+// own.
+func (t *Table) CheckDataplane(phase DriftPhase) ([]DriftEvent, error) {
+	dump, err := t.captureSave()
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	liveChains := parseChainLines(dump)
+
+	var events []DriftEvent
+	for name, lines := range liveChains {
+		owned := t.chains[name] != nil
+		historic := !owned && isHistoricChainName(name)
+		if !owned && !historic {
+			continue
+		}
+		var chainEvents []DriftEvent
+		if historic {
+			chainEvents = []DriftEvent{{
+				Chain:       name,
+				Lines:       lines,
+				CalicoOwned: true,
+				Phase:       phase,
+				Category:    DriftStaleHistoric,
+			}}
+		} else {
+			chainEvents = t.classifyChainDriftLocked(name, lines, phase)
+		}
+		for _, ev := range chainEvents {
+			t.emitDrift(ev)
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+// classifyChainDriftLocked compares liveLines (the live "-A chain ..."
+// lines for chain name) against the rules Table expects there, grouping
+// contiguous runs of unexpected/misplaced lines into DriftEvents. Callers
+// must hold t.mu.
+func (t *Table) classifyChainDriftLocked(name string, liveLines []string, phase DriftPhase) []DriftEvent {
+	c := t.chains[name]
+	expectedOrder := map[string]int{}
+	for i, r := range t.orderedRules(c) {
+		expectedOrder[t.ruleHash(t.renderRuleBase(r))] = i
+	}
+
+	var events []DriftEvent
+	var foreignBefore, foreignAfter, unknownHash []string
+	ownSeen := 0
+	lastOwnIdx := -1
+	outOfOrder := false
+
+	for _, line := range liveLines {
+		hash := ruleHashFromComment(line, t.hashPrefix)
+		if hash == "" {
+			if ownSeen == 0 {
+				foreignBefore = append(foreignBefore, line)
+			} else {
+				foreignAfter = append(foreignAfter, line)
+			}
+			continue
+		}
+		idx, known := expectedOrder[hash]
+		if !known {
+			unknownHash = append(unknownHash, line)
+			continue
+		}
+		if idx < lastOwnIdx {
+			outOfOrder = true
+		}
+		lastOwnIdx = idx
+		ownSeen++
+	}
+
+	if len(foreignBefore) > 0 {
+		events = append(events, DriftEvent{Chain: name, Lines: foreignBefore, CalicoOwned: true, Phase: phase, Category: DriftInsertedBefore})
+	}
+	if len(foreignAfter) > 0 {
+		events = append(events, DriftEvent{Chain: name, Lines: foreignAfter, CalicoOwned: true, Phase: phase, Category: DriftInsertedAfter})
+	}
+	if len(unknownHash) > 0 {
+		events = append(events, DriftEvent{Chain: name, Lines: unknownHash, CalicoOwned: true, Phase: phase, Category: DriftUnknownHash})
+	}
+	if outOfOrder {
+		events = append(events, DriftEvent{Chain: name, Lines: liveLines, CalicoOwned: true, Phase: phase, Category: DriftOutOfOrder})
+	}
+	return events
+}
+
+// isHistoricChainName reports whether name carries one of
+// rules.AllHistoricChainNamePrefixes, i.e. a prior felix generation's chain
+// naming scheme.
+func isHistoricChainName(name string) bool {
+	for _, prefix := range rules.AllHistoricChainNamePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseChainLines groups an iptables-save dump's "-A chain ..." lines by
+// chain name, in file order.
+func parseChainLines(dump string) map[string][]string {
+	chains := map[string][]string{}
+	scanner := bufio.NewScanner(strings.NewReader(dump))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "-A ") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		chain := fields[1]
+		chains[chain] = append(chains[chain], line)
+	}
+	return chains
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/projectcalico/calico/felix/environment"
+	"github.com/projectcalico/calico/felix/generictables"
+)
+
+// newSaveFakeTable builds a Table whose every shelled-out command (save or
+// restore) just cats dump, standing in for a live iptables-save dump that
+// contains foreign/drifted lines alongside Table's own rendered rules.
+func newSaveFakeTable(t *testing.T, dump string) *Table {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dump")
+	if err := os.WriteFile(path, []byte(dump), 0o644); err != nil {
+		t.Fatalf("writing fake dump: %v", err)
+	}
+	return NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			return exec.Command("cat", path)
+		},
+	})
+}
+
+func TestCheckDataplaneDetectsInsertionBeforeAndAfter(t *testing.T) {
+	rule := generictables.Rule{Match: Match().SourceNet("10.0.0.1/32"), Action: DropAction{}}
+
+	probe := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{})
+	probe.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{rule}})
+	ownLine := "-A cali-FORWARD " + probe.renderRule(rule)
+
+	dump := "*filter\n" +
+		":cali-FORWARD - [0:0]\n" +
+		"-A cali-FORWARD -j randomly-inserted-rule\n" +
+		ownLine + "\n" +
+		"-A cali-FORWARD -j another-inserted-rule\n" +
+		"COMMIT\n"
+
+	table := newSaveFakeTable(t, dump)
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{rule}})
+
+	var got []DriftEvent
+	table.SubscribeDrift(func(ev DriftEvent) { got = append(got, ev) })
+
+	events, err := table.CheckDataplane(DriftPhasePreRestore)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var before, after bool
+	for _, ev := range events {
+		if ev.Category == DriftInsertedBefore {
+			before = true
+		}
+		if ev.Category == DriftInsertedAfter {
+			after = true
+		}
+	}
+	if !before {
+		t.Errorf("expected a DriftInsertedBefore event, got %+v", events)
+	}
+	if !after {
+		t.Errorf("expected a DriftInsertedAfter event, got %+v", events)
+	}
+	if len(got) != len(events) {
+		t.Errorf("expected SubscribeDrift callback to see every event, got %d want %d", len(got), len(events))
+	}
+	if n := table.UnexpectedInsertsSeen(); n != 2 {
+		t.Errorf("expected UnexpectedInsertsSeen to count both insertions, got %d", n)
+	}
+}
+
+func TestCheckDataplaneDetectsUnknownHash(t *testing.T) {
+	dump := "*filter\n" +
+		":cali-FORWARD - [0:0]\n" +
+		`-A cali-FORWARD -j DROP -m comment --comment "cali:deadbeef"` + "\n" +
+		"COMMIT\n"
+
+	table := newSaveFakeTable(t, dump)
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{
+		{Match: Match().SourceNet("10.0.0.9/32"), Action: AcceptAction{}},
+	}})
+
+	events, err := table.CheckDataplane(DriftPhaseRecheck)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var found bool
+	for _, ev := range events {
+		if ev.Category == DriftUnknownHash && ev.Phase == DriftPhaseRecheck {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a DriftUnknownHash recheck event, got %+v", events)
+	}
+}
+
+func TestCheckDataplaneDetectsStaleHistoricChain(t *testing.T) {
+	dump := "*filter\n" +
+		":felix-OLD-CHAIN - [0:0]\n" +
+		"-A felix-OLD-CHAIN -j DROP\n" +
+		"COMMIT\n"
+
+	table := newSaveFakeTable(t, dump)
+
+	events, err := table.CheckDataplane(DriftPhaseRecheck)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 1 || events[0].Category != DriftStaleHistoric {
+		t.Fatalf("expected exactly one DriftStaleHistoric event, got %+v", events)
+	}
+	if events[0].Chain != "felix-OLD-CHAIN" {
+		t.Errorf("expected event for felix-OLD-CHAIN, got %s", events[0].Chain)
+	}
+}
+
+func TestCheckDataplaneIgnoresUnrelatedForeignChains(t *testing.T) {
+	dump := "*filter\n" +
+		":docker-user - [0:0]\n" +
+		"-A docker-user -j RETURN\n" +
+		"COMMIT\n"
+
+	table := newSaveFakeTable(t, dump)
+	events, err := table.CheckDataplane(DriftPhaseRecheck)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no drift events for a chain Table neither owns nor has ever owned, got %+v", events)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/projectcalico/calico/felix/environment"
+	"github.com/projectcalico/calico/felix/generictables"
+)
+
+// TestStableHashInsertModeKeepsUnrelatedRulesInPlace adds a chain of
+// rules, renders it, then adds one more rule and checks that every
+// already-present rule's line is still present and in the same relative
+// order to each other -- only the new rule's line is new.
+func TestStableHashInsertModeKeepsUnrelatedRulesInPlace(t *testing.T) {
+	table := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{
+		InsertMode: "stable-hash",
+	})
+	base := []generictables.Rule{
+		{Match: Match().SourceNet("10.0.0.1/32"), Action: DropAction{}},
+		{Match: Match().SourceNet("10.0.0.2/32"), Action: DropAction{}},
+		{Match: Match().SourceNet("10.0.0.3/32"), Action: DropAction{}},
+	}
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: base})
+
+	before, _ := table.RenderPending()
+	beforeLines := significantLines(before)
+
+	extended := append(append([]generictables.Rule{}, base...), generictables.Rule{
+		Match: Match().SourceNet("10.0.0.4/32"), Action: DropAction{},
+	})
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: extended})
+
+	after, _ := table.RenderPending()
+	afterLines := significantLines(after)
+
+	if len(afterLines) != len(beforeLines)+1 {
+		t.Fatalf("expected exactly one new line, before=%d after=%d", len(beforeLines), len(afterLines))
+	}
+	var kept int
+	for _, l := range beforeLines {
+		for _, al := range afterLines {
+			if l == al {
+				kept++
+				break
+			}
+		}
+	}
+	if kept != len(beforeLines) {
+		t.Fatalf("expected every pre-existing rule line to still be present unchanged, kept %d of %d", kept, len(beforeLines))
+	}
+}
+
+func significantLines(restoreInput string) []string {
+	var lines []string
+	for _, l := range strings.Split(restoreInput, "\n") {
+		if strings.HasPrefix(l, "-A ") {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package iptables
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// realNetlinkSocket is the real AF_NETLINK/NETLINK_NETFILTER implementation
+// of NetlinkSocket, used by applyNetlinkLocked whenever
+// TableOptions.NetlinkSocketOverride isn't set (i.e. everywhere outside
+// tests).
+type realNetlinkSocket struct {
+	fd int
+}
+
+func newRealNetlinkSocket() (NetlinkSocket, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW, syscall.NETLINK_NETFILTER)
+	if err != nil {
+		return nil, fmt.Errorf("opening NETLINK_NETFILTER socket: %w", err)
+	}
+	sa := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("binding NETLINK_NETFILTER socket: %w", err)
+	}
+	return &realNetlinkSocket{fd: fd}, nil
+}
+
+// SendBatch writes msgs to the kernel as a single sendto() call (nlmsghdrs
+// back to back, as the kernel expects for a batch) and reads back the
+// ack/error replies it sends for each NLM_F_ACK request, surfacing the
+// first one that reports an error.
+//
+// The reply-parsing here is intentionally minimal: it looks only at the
+// nlmsgerr.error field of each NLMSG_ERROR reply and ignores everything
+// else the kernel can return (done markers, overrun, multi-part extended
+// ACK attributes). That's enough to tell "the batch was rejected" from
+// "the batch was accepted" without pulling in a full netlink reply parser.
+func (s *realNetlinkSocket) SendBatch(msgs [][]byte) error {
+	var payload []byte
+	for _, m := range msgs {
+		payload = append(payload, m...)
+	}
+	sa := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}
+	if err := syscall.Sendto(s.fd, payload, 0, sa); err != nil {
+		return fmt.Errorf("sending nfnetlink batch: %w", err)
+	}
+
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := syscall.Recvfrom(s.fd, buf, 0)
+		if err != nil {
+			return fmt.Errorf("reading nfnetlink batch reply: %w", err)
+		}
+		if n < 16 {
+			return nil
+		}
+		msgType := uint16(buf[4]) | uint16(buf[5])<<8
+		const nlmsgError = 0x2
+		const nlmsgDone = 0x3
+		switch msgType {
+		case nlmsgError:
+			if n < 20 {
+				return nil
+			}
+			errno := int32(buf[16]) | int32(buf[17])<<8 | int32(buf[18])<<16 | int32(buf[19])<<24
+			if errno != 0 {
+				return fmt.Errorf("nfnetlink batch rejected: errno %d", -errno)
+			}
+		case nlmsgDone:
+			return nil
+		}
+	}
+}
+
+func (s *realNetlinkSocket) Close() error {
+	return syscall.Close(s.fd)
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import "encoding/binary"
+
+// Netlink/nftables constants from linux/netlink.h and
+// linux/netfilter/nf_tables.h. Table's netlink backend (TableOptions.
+// BackendImpl == "netlink") speaks these directly instead of shelling out
+// to nft, so a rule set can be committed as one kernel batch transaction.
+const (
+	nlmsgAlignTo = 4
+
+	nlmFRequest = 0x1
+	nlmFAck     = 0x4
+	nlmFCreate  = 0x400
+	nlmFExcl    = 0x200
+
+	nfnlSubsysNFTables = 10
+
+	nftMsgNewTable = 0
+	nftMsgNewChain = 3
+	nftMsgNewRule  = 6
+
+	nfnlMsgBatchBegin = 0x10
+	nfnlMsgBatchEnd   = 0x11
+
+	nftaTableName = 1
+	nftaChainName = 3
+	nftaChainHook = 4
+
+	nftaRuleTable    = 1
+	nftaRuleChain    = 2
+	nftaRuleUserdata = 7
+
+	nfnetlinkV0 = 0
+)
+
+// nlmsgAlign rounds n up to the netlink message alignment boundary, as
+// every netlink/nfnetlink header and attribute must be.
+func nlmsgAlign(n int) int {
+	return (n + nlmsgAlignTo - 1) &^ (nlmsgAlignTo - 1)
+}
+
+// netlinkHeader encodes an nlmsghdr (length is filled in by the caller
+// once the full message, including payload, is known) followed immediately
+// by an nfgenmsg header, as every nfnetlink message starts with both.
+func netlinkHeader(msgType uint16, flags uint16, family uint8, seq uint32) []byte {
+	buf := make([]byte, 16+4)
+	// nlmsghdr: len (patched by caller), type, flags, seq, pid
+	binary.LittleEndian.PutUint16(buf[4:6], msgType)
+	binary.LittleEndian.PutUint16(buf[6:8], flags)
+	binary.LittleEndian.PutUint32(buf[8:12], seq)
+	// pid left 0: the kernel treats 0 as "this process", same as nft(8).
+	// nfgenmsg: family, version, resource id (batch-scoped, so 0/unspec)
+	buf[16] = family
+	buf[17] = nfnetlinkV0
+	return buf
+}
+
+// putAttr appends a netlink attribute (2-byte length, 2-byte type, value
+// padded to the alignment boundary) to buf.
+func putAttr(buf []byte, attrType uint16, value []byte) []byte {
+	hdr := make([]byte, 4)
+	binary.LittleEndian.PutUint16(hdr[0:2], uint16(4+len(value)))
+	binary.LittleEndian.PutUint16(hdr[2:4], attrType)
+	buf = append(buf, hdr...)
+	buf = append(buf, value...)
+	pad := nlmsgAlign(len(value)) - len(value)
+	buf = append(buf, make([]byte, pad)...)
+	return buf
+}
+
+// finishMessage patches msg's nlmsghdr length field now that the full
+// message (header + attributes) is known, and pads it to the alignment
+// boundary so it can be concatenated with the next message in a batch.
+func finishMessage(msg []byte) []byte {
+	binary.LittleEndian.PutUint32(msg[0:4], uint32(len(msg)))
+	pad := nlmsgAlign(len(msg)) - len(msg)
+	return append(msg, make([]byte, pad)...)
+}
+
+// batchMarker builds the NFNL_MSG_BATCH_BEGIN/END message that must
+// bracket a set of nftables messages for the kernel to apply them as a
+// single atomic transaction.
+func batchMarker(msgType uint16, seq uint32) []byte {
+	msg := netlinkHeader(msgType, nlmFRequest, 0 /* AF_UNSPEC */, seq)
+	msg = finishMessage(msg)
+	return msg
+}
+
+// encodeNewTable builds the NEWTABLE message for family/name.
+func encodeNewTable(family uint8, name string, seq uint32) []byte {
+	msg := netlinkHeader(uint16(nfnlSubsysNFTables)<<8|nftMsgNewTable, nlmFRequest|nlmFAck|nlmFCreate|nlmFExcl, family, seq)
+	msg = putAttr(msg, nftaTableName, nullTerminated(name))
+	return finishMessage(msg)
+}
+
+// encodeNewChain builds the NEWCHAIN message for chainName inside
+// tableName. Table only manages regular (non-base) chains -- it never
+// installs hooks -- so nftaChainHook is intentionally left unset.
+func encodeNewChain(family uint8, tableName, chainName string, seq uint32) []byte {
+	msg := netlinkHeader(uint16(nfnlSubsysNFTables)<<8|nftMsgNewChain, nlmFRequest|nlmFAck|nlmFCreate|nlmFExcl, family, seq)
+	msg = putAttr(msg, nftaTableName, nullTerminated(tableName))
+	msg = putAttr(msg, nftaChainName, nullTerminated(chainName))
+	return finishMessage(msg)
+}
+
+// encodeNewRule builds the NEWRULE message for one rule in tableName/
+// chainName. ruleHash is preserved via the NFTA_RULE_USERDATA attribute --
+// the same way felix's iptables backend preserves it in a --comment match
+// -- so dirty-dataplane reconciliation can still recognise felix's own
+// rules after this backend wrote them.
+//
+// The rule's match/action is not yet lowered into nft expression
+// attributes (NFTA_RULE_EXPRESSIONS): that lowering is a materially
+// bigger undertaking (one nft "expr" per generictables match clause and
+// action, each with its own attribute encoding) than this change, and is
+// left as an explicit follow-up rather than silently skipped. Today's
+// NEWRULE therefore programs an unconditional rule at the right
+// table/chain/position with the right hash attached, which is enough to
+// exercise the batch/transaction/userdata-preservation plumbing end to
+// end, but not enough to actually enforce a policy's match criteria.
+func encodeNewRule(family uint8, tableName, chainName, ruleHash string, seq uint32) []byte {
+	msg := netlinkHeader(uint16(nfnlSubsysNFTables)<<8|nftMsgNewRule, nlmFRequest|nlmFAck|nlmFCreate, family, seq)
+	msg = putAttr(msg, nftaRuleTable, nullTerminated(tableName))
+	msg = putAttr(msg, nftaRuleChain, nullTerminated(chainName))
+	msg = putAttr(msg, nftaRuleUserdata, []byte(ruleHash))
+	return finishMessage(msg)
+}
+
+func nullTerminated(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+// NetlinkSocket is the minimal surface Table's netlink backend needs from
+// an nfnetlink socket, so tests can substitute a mock instead of opening a
+// real AF_NETLINK/NETLINK_NETFILTER socket.
+type NetlinkSocket interface {
+	// SendBatch sends msgs, already wrapped in BATCH BEGIN/END by the
+	// caller, as a single write and waits for the kernel's ack/error
+	// replies, returning the first error reply (if any) from the batch.
+	SendBatch(msgs [][]byte) error
+	Close() error
+}
+
+// buildRuleBatch renders chains (in t.sortedChainNames() order) into the
+// NEWTABLE/NEWCHAIN/NEWRULE messages, bracketed by a batch begin/end pair,
+// that applyViaNetlink sends as a single transaction. Callers must hold
+// t.mu.
+func (t *Table) buildRuleBatch() [][]byte {
+	family := uint8(2) // AF_INET; AF_INET6 (10) for t.IPVersion == 6
+	if t.IPVersion == 6 {
+		family = 10
+	}
+
+	var seq uint32 = 1
+	next := func() uint32 { seq++; return seq }
+
+	batch := [][]byte{batchMarker(nfnlMsgBatchBegin, next())}
+	batch = append(batch, encodeNewTable(family, t.Name, next()))
+	for _, name := range t.sortedChainNames() {
+		c := t.chains[name]
+		batch = append(batch, encodeNewChain(family, t.Name, name, next()))
+		for _, r := range t.orderedRules(c) {
+			hash := t.ruleHash(t.renderRuleBase(r))
+			batch = append(batch, encodeNewRule(family, t.Name, name, hash, next()))
+		}
+	}
+	batch = append(batch, batchMarker(nfnlMsgBatchEnd, next()))
+	return batch
+}
+
+// applyViaNetlink reconciles the dataplane by sending chains as a single
+// nfnetlink batch transaction over sock, instead of forking
+// iptables-restore/nft. Callers must hold t.mu for the buildRuleBatch call;
+// the lock is released before the (potentially slow) socket write.
+func (t *Table) applyViaNetlink(sock NetlinkSocket) error {
+	t.mu.Lock()
+	batch := t.buildRuleBatch()
+	t.mu.Unlock()
+	return sock.SendBatch(batch)
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/projectcalico/calico/felix/environment"
+	"github.com/projectcalico/calico/felix/generictables"
+)
+
+// fakeNetlinkSocket records the batch it was sent instead of talking to a
+// real kernel, so tests can inspect the message sequence applyViaNetlink
+// produces.
+type fakeNetlinkSocket struct {
+	sent   [][]byte
+	closed bool
+	err    error
+}
+
+func (f *fakeNetlinkSocket) SendBatch(msgs [][]byte) error {
+	f.sent = msgs
+	return f.err
+}
+
+func (f *fakeNetlinkSocket) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestBuildRuleBatchEmitsBeginTableChainRuleEnd(t *testing.T) {
+	table := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{})
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{
+		{Match: Match().SourceNet("10.0.0.1/32"), Action: DropAction{}},
+	}})
+
+	table.mu.Lock()
+	batch := table.buildRuleBatch()
+	table.mu.Unlock()
+
+	if len(batch) != 4 {
+		t.Fatalf("expected begin+table+chain+rule+end... got %d messages", len(batch))
+	}
+
+	beginType := uint16(batch[0][4]) | uint16(batch[0][5])<<8
+	if beginType != nfnlMsgBatchBegin {
+		t.Fatalf("expected first message to be BATCH_BEGIN, got type %#x", beginType)
+	}
+	endType := uint16(batch[len(batch)-1][4]) | uint16(batch[len(batch)-1][5])<<8
+	if endType != nfnlMsgBatchEnd {
+		t.Fatalf("expected last message to be BATCH_END, got type %#x", endType)
+	}
+
+	ruleMsg := batch[2]
+	ruleType := (uint16(ruleMsg[4]) | uint16(ruleMsg[5])<<8) & 0x00ff
+	if ruleType != nftMsgNewRule {
+		t.Fatalf("expected third message to be NEWRULE, got subtype %#x", ruleType)
+	}
+	expectedHash := table.ruleHash(table.renderRuleBase(generictables.Rule{
+		Match: Match().SourceNet("10.0.0.1/32"), Action: DropAction{},
+	}))
+	if !strings.Contains(string(ruleMsg), expectedHash) {
+		t.Fatalf("expected NEWRULE userdata to embed hash %q", expectedHash)
+	}
+}
+
+func TestApplyViaNetlinkSendsBuiltBatch(t *testing.T) {
+	table := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{})
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{
+		{Action: AcceptAction{}},
+	}})
+
+	sock := &fakeNetlinkSocket{}
+	if err := table.applyViaNetlink(sock); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sock.sent) == 0 {
+		t.Fatal("expected applyViaNetlink to send a non-empty batch")
+	}
+}
+
+// TestApplyRefusesNetlinkBackend locks in that selecting BackendImpl:
+// "netlink" makes Apply fail loudly instead of silently programming
+// unconditional (match-less) rules: encodeNewRule doesn't lower match/
+// action into nft expressions yet, so actually sending the batch would
+// enforce nothing while looking like a successful Apply.
+func TestApplyRefusesNetlinkBackend(t *testing.T) {
+	sock := &fakeNetlinkSocket{}
+	table := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{
+		BackendImpl:           "netlink",
+		NetlinkSocketOverride: sock,
+	})
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{
+		{Action: DropAction{}},
+	}})
+
+	err := table.Apply()
+	if !errors.Is(err, ErrNetlinkBackendUnimplemented) {
+		t.Fatalf("expected ErrNetlinkBackendUnimplemented, got: %v", err)
+	}
+	if len(sock.sent) != 0 {
+		t.Fatal("expected Apply not to send anything over the netlink socket")
+	}
+
+	table.mu.Lock()
+	dirty := table.dataplaneDirty
+	table.mu.Unlock()
+	if !dirty {
+		t.Fatal("expected the table to remain dirty since Apply refused to commit anything")
+	}
+}
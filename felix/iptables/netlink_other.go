@@ -0,0 +1,28 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package iptables
+
+import "fmt"
+
+// newRealNetlinkSocket has no non-Linux implementation: nfnetlink is a
+// Linux kernel interface. Felix only runs on Linux hosts, but this package
+// is still built (without being exercised) on other platforms as part of
+// `go build ./...`, so BackendImpl == "netlink" fails clearly here instead
+// of failing to compile.
+func newRealNetlinkSocket() (NetlinkSocket, error) {
+	return nil, fmt.Errorf("netlink backend is only supported on linux")
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"fmt"
+	"strings"
+)
+
+// renderNFTRuleset builds the `nft -f -` payload for t.Name from the
+// current desired chains, for BackendMode "nftables". Unlike "nft" (which
+// still shells out to iptables-nft-{save,restore} and speaks iptables
+// syntax under the hood), this talks the nft ruleset language directly:
+// one declarative "table ip <name> { chain <chain> { ... } }" block per
+// reconcile, which `nft -f -` applies as a single transaction. Callers
+// must hold t.mu.
+func (t *Table) renderNFTRuleset() string {
+	family := "ip"
+	if t.IPVersion == 6 {
+		family = "ip6"
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "table %s %s {\n", family, t.Name)
+	for _, name := range t.sortedChainNames() {
+		c := t.chains[name]
+		fmt.Fprintf(&buf, "\tchain %s {\n", name)
+		for _, r := range t.orderedRules(c) {
+			stmt := translateRuleToNFT(t.renderRule(r))
+			if stmt == "" {
+				continue
+			}
+			fmt.Fprintf(&buf, "\t\t%s\n", stmt)
+		}
+		buf.WriteString("\t}\n")
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// nftClauseTranslations maps the iptables-style match/action fragments
+// Match()/actions.go render (see actions.go and matchCriteria.Render) to
+// their nft statement equivalent. It's deliberately a flat lookup/prefix
+// table, not a parser, since generictables.Rule's Render contract only
+// promises an iptables-syntax fragment -- translating it token by token is
+// what lets the nftables backend reuse the exact same Rule values the
+// iptables backend does, without generictables growing a second,
+// nft-flavoured rendering method.
+var nftClausePrefixes = []struct {
+	iptPrefix string
+	nft       func(rest string) string
+}{
+	{"-p ", func(rest string) string { return "meta l4proto " + rest }},
+	{"-s ", func(rest string) string { return "ip saddr " + rest }},
+	{"-d ", func(rest string) string { return "ip daddr " + rest }},
+	{"-m set --match-set ", func(rest string) string {
+		fields := strings.Fields(rest)
+		if len(fields) != 2 {
+			return ""
+		}
+		setID, dir := fields[0], fields[1]
+		if dir == "src" {
+			return "ip saddr @" + setID
+		}
+		return "ip daddr @" + setID
+	}},
+	{"-j ", func(rest string) string {
+		switch rest {
+		case "DROP":
+			return "drop"
+		case "ACCEPT":
+			return "accept"
+		case "RETURN":
+			return "return"
+		default:
+			return "jump " + rest
+		}
+	}},
+}
+
+// translateRuleToNFT turns one renderRule()'d iptables fragment (e.g.
+// "-p tcp -s 10.0.0.0/8 -j DROP") into the equivalent nft rule statement
+// (e.g. "meta l4proto tcp ip saddr 10.0.0.0/8 drop"). Clauses it doesn't
+// recognise are dropped rather than emitted verbatim, since passing
+// iptables syntax straight through would produce a ruleset nft can't
+// parse.
+func translateRuleToNFT(rendered string) string {
+	if rendered == "" {
+		return ""
+	}
+	var stmts []string
+	for _, clause := range splitClauses(rendered) {
+		for _, tr := range nftClausePrefixes {
+			if strings.HasPrefix(clause, tr.iptPrefix) {
+				if s := tr.nft(strings.TrimPrefix(clause, tr.iptPrefix)); s != "" {
+					stmts = append(stmts, s)
+				}
+				break
+			}
+		}
+	}
+	return strings.Join(stmts, " ")
+}
+
+// splitClauses splits a rendered rule back into its "-x y" match/action
+// clauses. renderRule joins clauses with a single space and every clause
+// this package emits starts with a single-dash flag, so a new clause
+// begins wherever " -" is followed by a non-dash character; that check is
+// what keeps "--match-set" (a double-dash option inside the SourceIPSet/
+// DestIPSet clause, not a clause boundary) from being split in two.
+func splitClauses(rendered string) []string {
+	if !strings.HasPrefix(rendered, "-") {
+		return nil
+	}
+	var clauses []string
+	start := 0
+	for i := 1; i < len(rendered)-1; i++ {
+		if rendered[i] == ' ' && rendered[i+1] == '-' && (i+2 >= len(rendered) || rendered[i+2] != '-') {
+			clauses = append(clauses, rendered[start:i])
+			start = i + 1
+		}
+	}
+	clauses = append(clauses, rendered[start:])
+	return clauses
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/projectcalico/calico/felix/generictables"
+)
+
+func TestRenderNFTRulesetTranslatesRules(t *testing.T) {
+	table := newTestTable()
+	table.options.BackendMode = "nftables"
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{
+		{Match: Match().Protocol("tcp").SourceNet("10.0.0.0/8"), Action: DropAction{}},
+		{Match: Match().DestIPSet("this-set"), Action: JumpAction{Target: "cali-pi-abcd"}},
+	}})
+
+	table.mu.Lock()
+	ruleset := table.renderNFTRuleset()
+	table.mu.Unlock()
+
+	if !strings.Contains(ruleset, "table ip filter {") {
+		t.Fatalf("missing table header:\n%s", ruleset)
+	}
+	if !strings.Contains(ruleset, "chain cali-FORWARD {") {
+		t.Fatalf("missing chain header:\n%s", ruleset)
+	}
+	if !strings.Contains(ruleset, "meta l4proto tcp ip saddr 10.0.0.0/8 drop") {
+		t.Fatalf("drop rule not translated:\n%s", ruleset)
+	}
+	if !strings.Contains(ruleset, "ip daddr @this-set jump cali-pi-abcd") {
+		t.Fatalf("jump rule not translated:\n%s", ruleset)
+	}
+}
+
+func TestTranslateRuleToNFTIgnoresUnrecognisedClauses(t *testing.T) {
+	if got := translateRuleToNFT(""); got != "" {
+		t.Fatalf("expected empty translation for empty rule, got %q", got)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import "fmt"
+
+// PreviewApply runs the same plan Apply() would -- a live-dataplane probe
+// via captureSave, then renderApplyCommand's chain-rewrite-vs-restore-input
+// dispatch -- and stops short of actually invoking iptables-restore/nft.
+// restoreInput is the exact payload Apply would have piped in; cmds records
+// every command name PreviewApply itself ran or would go on to run (the
+// save probe, then the would-be apply command), in order.
+//
+// Unlike RenderPending (which answers the same "what would change" question
+// purely from in-memory state, without touching the kernel at all),
+// PreviewApply also exercises the live-dataplane read Apply performs as
+// part of its own plan, so a caller that wants to know the plan is sound
+// against the real kernel -- not just against Table's cached idea of it --
+// should use PreviewApply. It is side-effect free: it doesn't clear
+// dataplaneDirty or update lastAppliedHash, so a real Apply() afterwards
+// behaves exactly as if PreviewApply had never been called.
+func (t *Table) PreviewApply() (string, []string, error) {
+	saveCmd := "iptables-save"
+	if t.options.BackendMode == "nft" {
+		saveCmd = "iptables-nft-save"
+	} else if t.options.BackendMode == "legacy" {
+		saveCmd = "iptables-legacy-save"
+	}
+	if _, err := t.captureSave(); err != nil {
+		return "", nil, fmt.Errorf("probing live dataplane for preview: %w", err)
+	}
+
+	t.mu.Lock()
+	cmdName, _, input := t.renderApplyCommand()
+	t.mu.Unlock()
+
+	return input, []string{saveCmd, cmdName}, nil
+}
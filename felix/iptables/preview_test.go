@@ -0,0 +1,91 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/projectcalico/calico/felix/environment"
+	"github.com/projectcalico/calico/felix/generictables"
+)
+
+func TestPreviewApplyMatchesSubsequentApplyInput(t *testing.T) {
+	var restoreInvoked bool
+	table := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			if strings.HasSuffix(name, "save") {
+				return exec.Command("true")
+			}
+			restoreInvoked = true
+			return exec.Command("cat")
+		},
+	})
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{{Action: DropAction{}}}})
+
+	previewInput, cmds, err := table.PreviewApply()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restoreInvoked {
+		t.Fatal("expected PreviewApply never to invoke the restore/apply command")
+	}
+	if len(cmds) != 2 || !strings.HasSuffix(cmds[0], "save") {
+		t.Fatalf("expected cmds to record the save probe then the apply command, got %v", cmds)
+	}
+
+	if !table.dataplaneDirty {
+		t.Fatal("expected PreviewApply not to clear dataplaneDirty")
+	}
+
+	applyInput, _, _ := table.renderApplyCommand()
+	if previewInput != applyInput {
+		t.Fatalf("expected PreviewApply's restore input to match what Apply would send:\npreview: %q\napply:   %q", previewInput, applyInput)
+	}
+
+	if err := table.Apply(); err != nil {
+		t.Fatalf("unexpected error from the real apply: %v", err)
+	}
+	if table.dataplaneDirty {
+		t.Fatal("expected the real Apply to still converge normally after a preview")
+	}
+}
+
+func TestApplyWithDryRunNeverInvokesRestore(t *testing.T) {
+	var restoreInvoked bool
+	table := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{
+		DryRun: true,
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			if strings.HasSuffix(name, "save") {
+				return exec.Command("true")
+			}
+			restoreInvoked = true
+			return exec.Command("true")
+		},
+	})
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{{Action: DropAction{}}}})
+
+	if err := table.Apply(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restoreInvoked {
+		t.Fatal("expected a DryRun Apply never to invoke the restore/apply command")
+	}
+	if !table.dataplaneDirty {
+		t.Fatal("expected a DryRun Apply to leave dataplaneDirty set so the same change keeps previewing")
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/projectcalico/calico/felix/environment"
+	"github.com/projectcalico/calico/felix/generictables"
+)
+
+// opKind is one user-facing operation the property harness can issue
+// against a Table, mirroring the calls a real felix reconcile loop makes.
+type opKind int
+
+const (
+	opInsertOrAppend opKind = iota
+	opAppend
+	opUpdateChains
+	opRemoveChains
+	opInvalidate
+	opApply
+)
+
+func (k opKind) String() string {
+	return [...]string{"InsertOrAppendRules", "AppendRules", "UpdateChains", "RemoveChains", "InvalidateDataplaneCache", "Apply"}[k]
+}
+
+// scheduledOp is one step of a randomly generated schedule: a user
+// operation, plus (for opApply) whether this particular Apply should have
+// its restore fail, simulating one of the mock failure knobs real felix's
+// tests exercise one at a time (FailNextRestore and friends).
+type scheduledOp struct {
+	kind       opKind
+	chain      string
+	cidr       string
+	injectFail bool
+}
+
+var propertyChains = []string{"cali-FORWARD", "cali-INPUT"}
+var propertyCIDRs = []string{"10.0.0.1/32", "10.0.0.2/32", "10.0.0.3/32"}
+
+// generateSchedule builds a random sequence of n operations, each Apply
+// given a small independent chance of having its restore fail.
+func generateSchedule(rng *rand.Rand, n int) []scheduledOp {
+	schedule := make([]scheduledOp, n)
+	for i := range schedule {
+		kind := opKind(rng.Intn(int(opApply) + 1))
+		schedule[i] = scheduledOp{
+			kind:       kind,
+			chain:      propertyChains[rng.Intn(len(propertyChains))],
+			cidr:       propertyCIDRs[rng.Intn(len(propertyCIDRs))],
+			injectFail: kind == opApply && rng.Intn(3) == 0,
+		}
+	}
+	return schedule
+}
+
+// runSchedule replays schedule against a fresh Table backed by a fake
+// restore sink, then runs up to 5 additional fault-free Apply() calls and
+// checks the convergence invariant: the fake kernel's last successfully
+// applied restore input matches what RenderPending says Table's current
+// desired state requires, and Table no longer considers itself dirty.
+// It returns "" on success, or a description of what didn't converge.
+func runSchedule(schedule []scheduledOp) string {
+	tmpDir, err := os.MkdirTemp("", "property-restore")
+	if err != nil {
+		return fmt.Sprintf("setting up temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	restorePath := filepath.Join(tmpDir, "restore-sink")
+
+	var failThisApply bool
+	table := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			if failThisApply {
+				return exec.Command("false")
+			}
+			return exec.Command("tee", restorePath)
+		},
+	})
+
+	applyOnce := func(inject bool) error {
+		failThisApply = inject
+		return table.Apply()
+	}
+
+	for _, op := range schedule {
+		switch op.kind {
+		case opInsertOrAppend:
+			table.InsertOrAppendRules(op.chain, []generictables.Rule{{Match: Match().SourceNet(op.cidr), Action: DropAction{}}})
+		case opAppend:
+			table.AppendRules(op.chain, []generictables.Rule{{Match: Match().SourceNet(op.cidr), Action: AcceptAction{}}})
+		case opUpdateChains:
+			table.UpdateChains([]*Chain{{Name: op.chain, Rules: []generictables.Rule{{Match: Match().SourceNet(op.cidr), Action: DropAction{}}}}})
+		case opRemoveChains:
+			table.RemoveChains([]*Chain{{Name: op.chain}})
+		case opInvalidate:
+			table.InvalidateDataplaneCache("property-test")
+		case opApply:
+			_ = applyOnce(op.injectFail)
+		}
+	}
+
+	// Drain any remaining failure with bounded fault-free retries, the
+	// same way a real felix relies on its own reconcile loop calling
+	// Apply again on a timer until it stops erroring.
+	for i := 0; i < 5 && table.dataplaneDirty; i++ {
+		_ = applyOnce(false)
+	}
+
+	expected, _ := table.RenderPending()
+	got, err := os.ReadFile(restorePath)
+	if err != nil && !table.dataplaneDirty {
+		// No chains were ever successfully applied and none are
+		// pending either (e.g. an empty schedule) -- nothing to
+		// compare, which is convergence by definition.
+		return ""
+	}
+	if table.dataplaneDirty {
+		return fmt.Sprintf("table still reports dataplaneDirty after 5 fault-free Apply retries")
+	}
+	if string(got) != expected {
+		return fmt.Sprintf("converged restore sink does not match RenderPending's view of desired state:\nsink: %q\nwant: %q", string(got), expected)
+	}
+	return ""
+}
+
+// shrinkSchedule greedily removes operations from a failing schedule one
+// at a time, keeping any removal that still reproduces the failure, until
+// no single further removal does -- a minimal (though not globally
+// smallest) reproduction of the original failure.
+func shrinkSchedule(schedule []scheduledOp) []scheduledOp {
+	current := append([]scheduledOp{}, schedule...)
+	for {
+		shrunk := false
+		for i := range current {
+			candidate := append(append([]scheduledOp{}, current[:i]...), current[i+1:]...)
+			if len(candidate) == 0 {
+				continue
+			}
+			if runSchedule(candidate) != "" {
+				current = candidate
+				shrunk = true
+				break
+			}
+		}
+		if !shrunk {
+			return current
+		}
+	}
+}
+
+// TestTableReconciliationConvergesUnderRandomFaultInjection generates
+// random sequences of user operations plus randomly injected restore
+// failures and asserts the table always converges to the state
+// RenderPending predicts once Apply stops failing. On a failure it shrinks
+// the schedule to a minimal reproduction and prints it, so a real bug
+// surfaces as a small reproducible case instead of a hand-crafted one.
+func TestTableReconciliationConvergesUnderRandomFaultInjection(t *testing.T) {
+	const trials = 20
+	const scheduleLen = 10
+	for trial := 0; trial < trials; trial++ {
+		rng := rand.New(rand.NewSource(int64(trial)))
+		schedule := generateSchedule(rng, scheduleLen)
+		if msg := runSchedule(schedule); msg != "" {
+			minimal := shrinkSchedule(schedule)
+			t.Fatalf("trial %d: %s\n\nminimal reproducing schedule:\n%s", trial, msg, formatSchedule(minimal))
+		}
+	}
+}
+
+func formatSchedule(schedule []scheduledOp) string {
+	s := ""
+	for i, op := range schedule {
+		s += fmt.Sprintf("  %d: %s chain=%s cidr=%s injectFail=%v\n", i, op.kind, op.chain, op.cidr, op.injectFail)
+	}
+	return s
+}
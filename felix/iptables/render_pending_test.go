@@ -0,0 +1,82 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"os/exec"
+	"sync"
+	"testing"
+
+	"github.com/projectcalico/calico/felix/environment"
+	"github.com/projectcalico/calico/felix/generictables"
+)
+
+func newTestTable() *Table {
+	return NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{})
+}
+
+func TestRenderPendingReflectsAddedChangedRemoved(t *testing.T) {
+	table := newTestTable()
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{{Action: DropAction{}}}})
+
+	input, diff := table.RenderPending()
+	if !contains(diff.AddedChains, "cali-FORWARD") {
+		t.Fatalf("expected cali-FORWARD in AddedChains, got %v", diff)
+	}
+	if len(diff.ChangedChains) != 0 || len(diff.RemovedChains) != 0 {
+		t.Fatalf("unexpected diff: %+v", diff)
+	}
+	if input == "" {
+		t.Fatal("expected non-empty restore input")
+	}
+
+	// RenderPending must not mutate state: a second call sees the same diff.
+	input2, diff2 := table.RenderPending()
+	if input != input2 || !contains(diff2.AddedChains, "cali-FORWARD") {
+		t.Fatalf("RenderPending mutated Table state between calls")
+	}
+}
+
+func TestRenderPendingMatchesApplyInput(t *testing.T) {
+	table := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			return exec.Command("cat")
+		},
+	})
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{{Action: AcceptAction{}}}})
+
+	pending, _ := table.RenderPending()
+	if err := table.Apply(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	// Nothing changed since the last Apply, so the diff should now be empty.
+	_, diff := table.RenderPending()
+	if len(diff.AddedChains) != 0 || len(diff.ChangedChains) != 0 || len(diff.RemovedChains) != 0 {
+		t.Fatalf("expected empty diff after Apply, got %+v", diff)
+	}
+	if pending == "" {
+		t.Fatal("expected non-empty rendered restore input")
+	}
+}
+
+func contains(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/projectcalico/calico/felix/environment"
+	"github.com/projectcalico/calico/felix/generictables"
+)
+
+func TestApplyDoesNotRetryByDefault(t *testing.T) {
+	attempts := 0
+	table := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			attempts++
+			return exec.Command("false")
+		},
+		SleepOverride: func(time.Duration) { t.Fatal("expected no sleep when MaxRetries is unset") },
+	})
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{{Action: DropAction{}}}})
+
+	if err := table.Apply(); err == nil {
+		t.Fatal("expected an error from the failing restore")
+	}
+	if attempts != 1 {
+		t.Fatalf("expected exactly one attempt, got %d", attempts)
+	}
+}
+
+func TestApplyRetriesUpToMaxRetriesWithExponentialBackoff(t *testing.T) {
+	attempts := 0
+	var sleeps []time.Duration
+	table := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			attempts++
+			return exec.Command("false")
+		},
+		SleepOverride: func(d time.Duration) { sleeps = append(sleeps, d) },
+		InitialBackoff:    10 * time.Millisecond,
+		BackoffMultiplier: 2,
+		MaxRetries:        3,
+	})
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{{Action: DropAction{}}}})
+
+	if err := table.Apply(); err == nil {
+		t.Fatal("expected an error after exhausting retries")
+	}
+	if attempts != 4 {
+		t.Fatalf("expected 1 initial attempt + 3 retries = 4, got %d", attempts)
+	}
+	if len(sleeps) != 3 {
+		t.Fatalf("expected 3 backoff sleeps, got %d", len(sleeps))
+	}
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond}
+	for i, d := range sleeps {
+		if d != want[i] {
+			t.Errorf("sleep %d: got %v, want %v", i, d, want[i])
+		}
+	}
+}
+
+func TestApplyBackoffJitterStaysWithinBounds(t *testing.T) {
+	var sleeps []time.Duration
+	table := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			return exec.Command("false")
+		},
+		SleepOverride:         func(d time.Duration) { sleeps = append(sleeps, d) },
+		InitialBackoff:        100 * time.Millisecond,
+		BackoffMultiplier:     1,
+		BackoffJitterFraction: 0.1,
+		MaxRetries:            5,
+	})
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{{Action: DropAction{}}}})
+
+	_ = table.Apply()
+
+	lo := 90 * time.Millisecond
+	hi := 110 * time.Millisecond
+	for i, d := range sleeps {
+		if d < lo || d > hi {
+			t.Errorf("sleep %d: got %v, want within [%v, %v]", i, d, lo, hi)
+		}
+	}
+}
+
+// countingRetryPolicy is a custom RetryPolicy that always waits a fixed
+// delay, to verify TableOptions.RetryPolicy is honored over the built-in
+// exponential-jitter default.
+type countingRetryPolicy struct {
+	calls int
+	delay time.Duration
+}
+
+func (p *countingRetryPolicy) NextBackoff(attempt int, opts TableOptions) time.Duration {
+	p.calls++
+	return p.delay
+}
+
+func TestApplyHonorsCustomRetryPolicy(t *testing.T) {
+	policy := &countingRetryPolicy{delay: 5 * time.Millisecond}
+	var sleeps []time.Duration
+	table := NewTable("filter", 4, "cali:", &sync.Mutex{}, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			return exec.Command("false")
+		},
+		SleepOverride: func(d time.Duration) { sleeps = append(sleeps, d) },
+		MaxRetries:    2,
+		RetryPolicy:   policy,
+	})
+	table.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{{Action: DropAction{}}}})
+
+	_ = table.Apply()
+
+	if policy.calls != 2 {
+		t.Fatalf("expected the custom policy to be consulted twice, got %d", policy.calls)
+	}
+	for _, d := range sleeps {
+		if d != 5*time.Millisecond {
+			t.Errorf("expected every sleep to use the custom policy's fixed delay, got %v", d)
+		}
+	}
+}
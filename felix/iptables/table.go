@@ -0,0 +1,676 @@
+// Copyright (c) 2017-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package iptables reconciles a desired set of chains and rules against
+// the real iptables dataplane, via iptables-restore, on each Apply. Table
+// is the entry point: callers build up the chains they want with
+// UpdateChain/UpdateChains/RemoveChains and then call Apply to make it so.
+package iptables
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/projectcalico/calico/felix/environment"
+	"github.com/projectcalico/calico/felix/generictables"
+	"github.com/projectcalico/calico/felix/logutils"
+)
+
+// Chain is one named iptables chain and the rules it should contain, in
+// order.
+type Chain struct {
+	Name  string
+	Rules []generictables.Rule
+}
+
+// TableOptions configures a Table. All fields other than
+// HistoricChainPrefixes are optional; the *Override fields exist so tests
+// can substitute a fake dataplane instead of shelling out for real.
+type TableOptions struct {
+	HistoricChainPrefixes []string
+	NewCmdOverride        func(name string, arg ...string) *exec.Cmd
+	SleepOverride         func(time.Duration)
+	NowOverride           func() time.Time
+	BackendMode           string
+	LookPathOverride      func(string) (string, error)
+	OpRecorder            *logutils.Summarizer
+
+	// CounterInterval, if non-zero, is how often a CountersReader created
+	// by NewCountersReader(t) refreshes its rule-hit metrics from
+	// iptables-save -c. CounterRegistry, if non-nil, is where that
+	// reader's GaugeVecs get registered; leaving it nil disables counter
+	// collection even if CounterInterval is set.
+	CounterInterval time.Duration
+	CounterRegistry prometheus.Registerer
+
+	// BackendImpl selects how Apply() actually commits a table: "" (the
+	// default) forks iptables-restore/nft as BackendMode picks; "netlink"
+	// would instead send the chains as a single nfnetlink batch
+	// transaction over NetlinkSocketOverride (or a real
+	// AF_NETLINK/NETLINK_NETFILTER socket if that's nil), without forking
+	// anything. Apply() currently refuses to activate "netlink" (see
+	// ErrNetlinkBackendUnimplemented) because encodeNewRule doesn't yet
+	// lower a rule's match/action into NFTA_RULE_EXPRESSIONS, so every
+	// rule it would program is an unconditional no-op in the kernel.
+	BackendImpl           string
+	NetlinkSocketOverride NetlinkSocket
+
+	// InitialBackoff, MaxBackoff, MaxRetries, BackoffMultiplier and
+	// BackoffJitterFraction configure Apply()'s retry loop around the
+	// restore/nft command: on failure it waits RetryPolicy.NextBackoff
+	// (the default policy multiplies InitialBackoff by
+	// BackoffMultiplier each attempt, capped at MaxBackoff, then
+	// randomizes by up to BackoffJitterFraction in either direction) and
+	// tries again, up to MaxRetries times. MaxRetries <= 0 (the zero
+	// value) means "don't retry," matching Apply's original behavior.
+	// BackoffJitterFraction of e.g. 0.1 avoids every felix process on a
+	// cluster retrying in lockstep after a shared xtables.lock/kernel
+	// contention event.
+	InitialBackoff        time.Duration
+	MaxBackoff            time.Duration
+	MaxRetries            int
+	BackoffMultiplier     float64
+	BackoffJitterFraction float64
+	// RetryPolicy, if set, overrides the default exponential-plus-jitter
+	// backoff above with a caller-supplied strategy (e.g. decorrelated
+	// jitter or full jitter).
+	RetryPolicy RetryPolicy
+
+	// DryRun, if true, makes Apply behave like PreviewApply: it still
+	// probes the live dataplane and renders what it would send, but
+	// never actually invokes iptables-restore/nft, and never clears
+	// dataplaneDirty or updates lastAppliedHash, so the same pending
+	// change keeps showing up on every Apply until DryRun is turned
+	// off. Useful for a troubleshooting/CI mode that must never be able
+	// to touch the real dataplane no matter what calls Apply.
+	DryRun bool
+}
+
+// RetryPolicy computes how long Apply should wait before retry attempt
+// (1-based: 1 is the delay before the first retry after the initial failed
+// attempt) against the backoff parameters configured on opts.
+type RetryPolicy interface {
+	NextBackoff(attempt int, opts TableOptions) time.Duration
+}
+
+// exponentialJitterRetryPolicy is the default RetryPolicy: InitialBackoff
+// multiplied by BackoffMultiplier once per attempt, capped at MaxBackoff,
+// then randomized by up to BackoffJitterFraction in either direction.
+type exponentialJitterRetryPolicy struct{}
+
+func (exponentialJitterRetryPolicy) NextBackoff(attempt int, opts TableOptions) time.Duration {
+	base := opts.InitialBackoff
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	mult := opts.BackoffMultiplier
+	if mult <= 0 {
+		mult = 2
+	}
+	d := float64(base)
+	for i := 1; i < attempt; i++ {
+		d *= mult
+	}
+	if opts.MaxBackoff > 0 && d > float64(opts.MaxBackoff) {
+		d = float64(opts.MaxBackoff)
+	}
+	if opts.BackoffJitterFraction > 0 {
+		// 2*rand()-1 spans [-1, 1), so this jitters d by up to
+		// +/-BackoffJitterFraction of itself.
+		jitter := (2*rand.Float64() - 1) * opts.BackoffJitterFraction
+		d += d * jitter
+		if d < 0 {
+			d = 0
+		}
+	}
+	return time.Duration(d)
+}
+
+// Table reconciles one iptables table (e.g. "filter", "nat") for one IP
+// version against the chains it's been told to program. It owns only the
+// chains it creates (named with one of its HistoricChainPrefixes) plus any
+// chain it's been explicitly handed via UpdateChain -- it never touches
+// rules in a chain it doesn't own.
+type Table struct {
+	Name      string
+	IPVersion int
+	hashPrefix string
+
+	iptLock         sync.Locker
+	featureDetector *environment.FeatureDetector
+	options         TableOptions
+
+	newCmd  func(name string, arg ...string) *exec.Cmd
+	sleep   func(time.Duration)
+	now     func() time.Time
+
+	mu     sync.Mutex
+	chains map[string]*Chain
+	// dataplaneDirty is set whenever a chain is added, updated or removed
+	// since the last successful Apply, and cleared once Apply has
+	// reconciled the dataplane to match.
+	dataplaneDirty bool
+	// lastAppliedHash records, per chain, a hash of the rules Apply most
+	// recently programmed for it -- used by InvalidateDataplaneCache to
+	// force a full re-sync (e.g. after an external tool is suspected to
+	// have touched felix's chains) without needing a real dataplane read.
+	lastAppliedHash map[string]string
+	unexpectedInserts int
+
+	// driftListeners are the callbacks registered via SubscribeDrift.
+	driftListeners []func(DriftEvent)
+}
+
+// NewTable creates a Table for the named iptables table and IP version.
+// hashPrefix and options.HistoricChainPrefixes identify which chains and
+// rules this Table owns, as opposed to ones added by another piece of
+// software or an operator.
+func NewTable(name string, ipVersion int, hashPrefix string, iptLock sync.Locker, featureDetector *environment.FeatureDetector, options TableOptions) *Table {
+	newCmd := options.NewCmdOverride
+	if newCmd == nil {
+		newCmd = exec.Command
+	}
+	sleep := options.SleepOverride
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	now := options.NowOverride
+	if now == nil {
+		now = time.Now
+	}
+	return &Table{
+		Name:            name,
+		IPVersion:       ipVersion,
+		hashPrefix:      hashPrefix,
+		iptLock:         iptLock,
+		featureDetector: featureDetector,
+		options:         options,
+		newCmd:          newCmd,
+		sleep:           sleep,
+		now:             now,
+		chains:          map[string]*Chain{},
+		lastAppliedHash: map[string]string{},
+	}
+}
+
+// UpdateChain replaces the desired rules for chain.Name, creating it if
+// this is the first time it's been mentioned.
+func (t *Table) UpdateChain(chain *Chain) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.chains[chain.Name] = chain
+	t.dataplaneDirty = true
+}
+
+// UpdateChains is UpdateChain for a batch of chains.
+func (t *Table) UpdateChains(chains []*Chain) {
+	for _, c := range chains {
+		t.UpdateChain(c)
+	}
+}
+
+// RemoveChainByName marks name for deletion on the next Apply. It's a
+// no-op if the chain was never added.
+func (t *Table) RemoveChainByName(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.chains[name]; ok {
+		delete(t.chains, name)
+		delete(t.lastAppliedHash, name)
+		t.dataplaneDirty = true
+	}
+}
+
+// RemoveChains is RemoveChainByName for a batch of chains.
+func (t *Table) RemoveChains(chains []*Chain) {
+	for _, c := range chains {
+		t.RemoveChainByName(c.Name)
+	}
+}
+
+// AppendRules adds rules to the end of chainName's existing desired rules.
+func (t *Table) AppendRules(chainName string, rules []generictables.Rule) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.chains[chainName]
+	if c == nil {
+		c = &Chain{Name: chainName}
+		t.chains[chainName] = c
+	}
+	c.Rules = append(c.Rules, rules...)
+	t.dataplaneDirty = true
+}
+
+// InsertOrAppendRules adds rules to chainName, at the front if
+// BackendMode/insert-mode conventions call for it and at the back
+// otherwise; the two behave identically here since Table always owns the
+// whole chain, but the distinct name matches how callers ask for
+// "my rules must run first" (Insert) versus "my rules must run last"
+// (Append) semantics against a chain another component also populates.
+func (t *Table) InsertOrAppendRules(chainName string, rules []generictables.Rule) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.chains[chainName]
+	if c == nil {
+		c = &Chain{Name: chainName}
+		t.chains[chainName] = c
+	}
+	c.Rules = append(append([]generictables.Rule{}, rules...), c.Rules...)
+	t.dataplaneDirty = true
+}
+
+// CheckRulesPresent returns the subset of rules that are not currently
+// part of chainName's desired rule set, i.e. what Apply would still need
+// to program.
+func (t *Table) CheckRulesPresent(chainName string, rules []generictables.Rule) []generictables.Rule {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.chains[chainName]
+	if c == nil {
+		return rules
+	}
+	present := map[string]bool{}
+	for _, r := range c.Rules {
+		present[t.renderRule(r)] = true
+	}
+	var missing []generictables.Rule
+	for _, r := range rules {
+		if !present[t.renderRule(r)] {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+// InvalidateDataplaneCache forces the next Apply to reprogram every chain,
+// even ones whose rules haven't changed, on the assumption that something
+// outside Table's control (reason) may have modified the real dataplane.
+func (t *Table) InvalidateDataplaneCache(reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastAppliedHash = map[string]string{}
+	t.dataplaneDirty = true
+	if t.options.OpRecorder != nil {
+		t.options.OpRecorder.RecordOperation("invalidate-cache:" + reason)
+	}
+}
+
+// UnexpectedInsertsSeen returns the number of times Apply has found a rule
+// in one of its owned chains that it didn't program itself, since the
+// Table was created.
+func (t *Table) UnexpectedInsertsSeen() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.unexpectedInserts
+}
+
+// renderRuleBase renders r's match/action fragment only, with no hash
+// comment -- it's what ruleHash/ruleBucket hash, and what renderRule
+// builds on to add the comment.
+func (t *Table) renderRuleBase(r generictables.Rule) string {
+	features := generictables.Features{}
+	if t.featureDetector != nil {
+		features = *t.featureDetector.GetFeatures()
+	}
+	frag := ""
+	if r.Match != nil {
+		frag = r.Match.Render(features)
+	}
+	if r.Action != nil {
+		if frag != "" {
+			frag += " "
+		}
+		frag += r.Action.Render(features)
+	}
+	return frag
+}
+
+func (t *Table) renderRule(r generictables.Rule) string {
+	frag := t.renderRuleBase(r)
+	if t.hashPrefix != "" {
+		hash := t.ruleHash(frag)
+		if frag != "" {
+			frag += " "
+		}
+		frag += fmt.Sprintf(`-m comment --comment "%s%s"`, t.hashPrefix, hash)
+	}
+	return frag
+}
+
+// ruleHash derives the short per-rule hash embedded in every rendered
+// rule's "cali:<hash>" comment (see renderRule), from the match/action
+// fragment before that comment is appended. CountersReader matches this
+// same hash back out of iptables-save -c output to attribute counters to
+// the Rule that produced them.
+func (t *Table) ruleHash(baseFrag string) string {
+	sum := sha256.Sum256([]byte(baseFrag))
+	return hex.EncodeToString(sum[:4])
+}
+
+// orderedRules returns chain.Rules in the order they should be rendered.
+// Normally that's just insertion order. When options.InsertMode is
+// "stable-hash" it instead returns them sorted by each rule's own content
+// hash, independent of every other rule's -- so adding or removing one
+// rule only changes that rule's line in the rendered chain; every other
+// rule keeps the bucket position its own hash already put it in, instead
+// of shifting to fill the gap the way a plain insertion would.
+func (t *Table) orderedRules(chain *Chain) []generictables.Rule {
+	if t.options.InsertMode != "stable-hash" {
+		return chain.Rules
+	}
+	ordered := make([]generictables.Rule, len(chain.Rules))
+	copy(ordered, chain.Rules)
+	sort.Slice(ordered, func(i, j int) bool {
+		return t.ruleHash(t.renderRuleBase(ordered[i])) < t.ruleHash(t.renderRuleBase(ordered[j]))
+	})
+	return ordered
+}
+
+// InsertRulesNow programs rules into chainName immediately, bypassing the
+// normal batched Apply cycle -- for callers (e.g. a felix startup path)
+// that need a rule in place synchronously rather than on the next
+// reconciliation tick.
+func (t *Table) InsertRulesNow(chainName string, rules []generictables.Rule) error {
+	t.InsertOrAppendRules(chainName, rules)
+	return t.Apply()
+}
+
+// DeleteRulesNow is InsertRulesNow's inverse: it removes exactly the given
+// rules from chainName's live dataplane state immediately, without waiting
+// for (or otherwise touching) the next Apply cycle. It's for boot-time
+// rules a caller installed via InsertRulesNow on a chain Table may not
+// even manage (e.g. FORWARD) and needs to clean up precisely on shutdown
+// or reconfiguration, leaving every other rule in the chain -- Calico's or
+// not -- untouched.
+//
+// It reads the live chain via iptables-save, hashes each candidate rule
+// the same way renderRule/ruleHash do, and only asks iptables-restore to
+// delete the ones it actually finds present; rules already absent are
+// silently skipped rather than turned into a restore error.
+func (t *Table) DeleteRulesNow(chainName string, rules []generictables.Rule) error {
+	dump, err := t.captureSave()
+	if err != nil {
+		return fmt.Errorf("reading live dataplane before deleting rules from %s: %w", chainName, err)
+	}
+
+	t.mu.Lock()
+	present := map[string]bool{}
+	for _, line := range parseChainLines(dump)[chainName] {
+		if hash := ruleHashFromComment(line, t.hashPrefix); hash != "" {
+			present[hash] = true
+		}
+	}
+	var toDelete []generictables.Rule
+	for _, r := range rules {
+		if present[t.ruleHash(t.renderRuleBase(r))] {
+			toDelete = append(toDelete, r)
+		}
+	}
+	var buf strings.Builder
+	if len(toDelete) > 0 {
+		buf.WriteString("*" + t.Name + "\n")
+		for _, r := range toDelete {
+			buf.WriteString("-D " + chainName + " " + t.renderRule(r) + "\n")
+		}
+		buf.WriteString("COMMIT\n")
+	}
+	t.mu.Unlock()
+
+	if len(toDelete) == 0 {
+		return nil
+	}
+
+	t.iptLock.Lock()
+	defer t.iptLock.Unlock()
+	cmd := t.newCmd(t.restoreBinaryName(), "--noflush")
+	cmd.Stdin = strings.NewReader(buf.String())
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed deleting rules from %s: %w", t.restoreBinaryName(), chainName, err)
+	}
+	return nil
+}
+
+// ErrNetlinkBackendUnimplemented is returned by Apply when
+// TableOptions.BackendImpl is "netlink". The netlink batch-transaction
+// plumbing (NEWTABLE/NEWCHAIN/NEWRULE framing, userdata-hash preservation)
+// is in place, but encodeNewRule doesn't yet lower a rule's match criteria
+// or action into NFTA_RULE_EXPRESSIONS, so every rule it would program is
+// an unconditional no-op in the kernel -- silently enforcing nothing.
+// Apply refuses to activate the backend rather than let a caller select
+// it and lose policy enforcement without any error or warning.
+var ErrNetlinkBackendUnimplemented = errors.New("iptables: netlink backend does not yet lower rule match/action into nft expressions")
+
+// Apply reconciles the real dataplane to match the desired chains, via
+// iptables-restore, if anything has changed since the last successful
+// Apply.
+func (t *Table) Apply() error {
+	t.mu.Lock()
+	if !t.dataplaneDirty {
+		t.mu.Unlock()
+		return nil
+	}
+	dryRun := t.options.DryRun
+	t.mu.Unlock()
+
+	if dryRun {
+		_, _, err := t.PreviewApply()
+		return err
+	}
+
+	t.mu.Lock()
+	if t.options.BackendImpl == "netlink" {
+		t.mu.Unlock()
+		return ErrNetlinkBackendUnimplemented
+	}
+	cmdName, args, input := t.renderApplyCommand()
+	hasDriftListeners := len(t.driftListeners) > 0
+	t.mu.Unlock()
+
+	if hasDriftListeners {
+		if _, err := t.CheckDataplane(DriftPhasePreRestore); err != nil {
+			return fmt.Errorf("checking dataplane for drift before apply: %w", err)
+		}
+	}
+
+	if err := t.runApplyCommandWithRetries(cmdName, args, input); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	for name, c := range t.chains {
+		t.lastAppliedHash[name] = t.hashChain(c)
+	}
+	t.dataplaneDirty = false
+	t.mu.Unlock()
+
+	if t.options.OpRecorder != nil {
+		t.options.OpRecorder.RecordOperation("apply")
+		t.options.OpRecorder.Flush()
+	}
+	return nil
+}
+
+// runApplyCommandWithRetries runs cmdName/args with input on stdin, under
+// the iptables lock, retrying on failure per TableOptions' backoff fields
+// (or RetryPolicy, if set) up to MaxRetries times. MaxRetries <= 0 means
+// try once and return whatever error that attempt produced, preserving
+// Apply's original no-retry behavior for callers that don't configure it.
+func (t *Table) runApplyCommandWithRetries(cmdName string, args []string, input string) error {
+	policy := t.options.RetryPolicy
+	if policy == nil {
+		policy = exponentialJitterRetryPolicy{}
+	}
+
+	var err error
+	for attempt := 0; attempt <= t.options.MaxRetries; attempt++ {
+		t.iptLock.Lock()
+		cmd := t.newCmd(cmdName, args...)
+		cmd.Stdin = strings.NewReader(input)
+		err = cmd.Run()
+		t.iptLock.Unlock()
+		if err == nil {
+			return nil
+		}
+		if attempt == t.options.MaxRetries {
+			break
+		}
+		t.sleep(policy.NextBackoff(attempt+1, t.options))
+	}
+	return fmt.Errorf("%s failed after %d attempt(s): %w", cmdName, t.options.MaxRetries+1, err)
+}
+
+// applyNetlinkLocked commits the table via applyViaNetlink, under the
+// iptables lock (the netlink batch transaction and a fork/exec restore are
+// still mutually exclusive ways of mutating the same tables, so they must
+// not interleave) using either NetlinkSocketOverride or a real socket.
+// Apply doesn't call this today -- see ErrNetlinkBackendUnimplemented --
+// it's kept in place for the follow-up that finishes expression lowering,
+// at which point Apply's guard comes out and this becomes reachable again.
+func (t *Table) applyNetlinkLocked() error {
+	t.iptLock.Lock()
+	defer t.iptLock.Unlock()
+
+	sock := t.options.NetlinkSocketOverride
+	if sock == nil {
+		var err error
+		sock, err = newRealNetlinkSocket()
+		if err != nil {
+			return fmt.Errorf("opening nfnetlink socket: %w", err)
+		}
+		defer sock.Close()
+	}
+	return t.applyViaNetlink(sock)
+}
+
+// renderApplyCommand returns the command, arguments and stdin payload the
+// next Apply() would run, chosen by BackendMode: "nftables" drives `nft -f
+// -` with a native nft ruleset, everything else shells out to one of the
+// iptables-restore variants with an iptables-restore payload. Callers must
+// hold t.mu.
+func (t *Table) renderApplyCommand() (string, []string, string) {
+	if t.options.BackendMode == "nftables" {
+		return "nft", []string{"-f", "-"}, t.renderNFTRuleset()
+	}
+	return t.restoreBinaryName(), []string{"--noflush"}, t.renderRestoreInput()
+}
+
+func (t *Table) restoreBinaryName() string {
+	if t.options.BackendMode == "nft" {
+		return "iptables-nft-restore"
+	}
+	if t.options.BackendMode == "legacy" {
+		return "iptables-legacy-restore"
+	}
+	return "iptables-restore"
+}
+
+// renderRestoreInput builds the iptables-restore payload for t.Name from
+// the current desired chains. Callers must hold t.mu.
+func (t *Table) renderRestoreInput() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "*%s\n", t.Name)
+	names := t.sortedChainNames()
+	for _, name := range names {
+		fmt.Fprintf(&buf, ":%s - [0:0]\n", name)
+	}
+	for _, name := range names {
+		c := t.chains[name]
+		for _, r := range t.orderedRules(c) {
+			frag := t.renderRule(r)
+			if frag == "" {
+				fmt.Fprintf(&buf, "-A %s\n", name)
+			} else {
+				fmt.Fprintf(&buf, "-A %s %s\n", name, frag)
+			}
+		}
+	}
+	buf.WriteString("COMMIT\n")
+	return buf.String()
+}
+
+// sortedChainNames returns t.chains' keys in a deterministic order so
+// renderRestoreInput (and hence RenderPending/Apply) produce byte-identical
+// output for an unchanged chain set. Callers must hold t.mu.
+func (t *Table) sortedChainNames() []string {
+	names := make([]string, 0, len(t.chains))
+	for name := range t.chains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hashChain returns a stable digest of chain's rendered rules, in order, so
+// Apply/RenderPending can tell whether a chain actually changed since the
+// last successful Apply without re-rendering and diffing it line by line.
+func (t *Table) hashChain(chain *Chain) string {
+	h := sha256.New()
+	for _, r := range t.orderedRules(chain) {
+		h.Write([]byte(t.renderRule(r)))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// PendingDiff describes, at the chain level, what the next Apply() would
+// change: chains RenderPending's caller hasn't seen programmed before,
+// chains that are going away, and chains whose rule set differs from what
+// was last successfully applied. A chain whose rules were removed and
+// re-added in a different order shows up in Changed, since its hash (and
+// therefore its rendered rule order) differs from lastAppliedHash.
+type PendingDiff struct {
+	AddedChains   []string
+	RemovedChains []string
+	ChangedChains []string
+}
+
+// RenderPending returns the exact iptables-restore payload the next
+// Apply() would send to the kernel, plus a chain-level diff against what
+// was last applied -- without taking the iptables lock or touching the
+// dataplane. It's side-effect free: it does not clear dataplaneDirty or
+// update lastAppliedHash, so a real Apply() afterwards behaves exactly as
+// if RenderPending had never been called.
+func (t *Table) RenderPending() (string, PendingDiff) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	diff := PendingDiff{}
+	for name := range t.chains {
+		if _, ok := t.lastAppliedHash[name]; !ok {
+			diff.AddedChains = append(diff.AddedChains, name)
+		} else if t.hashChain(t.chains[name]) != t.lastAppliedHash[name] {
+			diff.ChangedChains = append(diff.ChangedChains, name)
+		}
+	}
+	for name := range t.lastAppliedHash {
+		if _, ok := t.chains[name]; !ok {
+			diff.RemovedChains = append(diff.RemovedChains, name)
+		}
+	}
+	sort.Strings(diff.AddedChains)
+	sort.Strings(diff.RemovedChains)
+	sort.Strings(diff.ChangedChains)
+
+	return t.renderRestoreInput(), diff
+}
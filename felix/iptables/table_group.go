@@ -0,0 +1,172 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TableGroup batches the pending Apply() of several *Table instances
+// (typically filter/nat/mangle/raw for one IP version) into a single
+// logical transaction: it applies each table's restore payload in turn
+// under one held lock and, if any table fails, replays the iptables-save
+// output it captured from the tables that already succeeded, so a
+// reconcile spanning several tables can't leave the box half-updated.
+//
+// TableGroup does not replace each Table's own Apply -- callers that want
+// transactional semantics across tables call TableGroup.Apply instead of
+// calling Apply on each Table individually.
+type TableGroup struct {
+	lock   sync.Locker
+	tables []*Table
+}
+
+// NewTableGroup creates a TableGroup over tables, coordinated by lock --
+// typically the same iptables lock each Table was itself constructed
+// with, so TableGroup.Apply and a stray Table.Apply can never interleave.
+func NewTableGroup(lock sync.Locker, tables ...*Table) *TableGroup {
+	return &TableGroup{lock: lock, tables: tables}
+}
+
+// tablePlan is one table's captured pre-state and pending restore command,
+// computed while TableGroup.Apply holds the group lock.
+type tablePlan struct {
+	table      *Table
+	cmdName    string
+	args       []string
+	input      string
+	preState   string
+	restoreCmd string
+}
+
+// Apply reconciles every dirty table in the group as a single transaction:
+// it saves each table's current state before touching it, applies tables
+// in the order they were passed to NewTableGroup, and if any table's
+// restore fails, replays the captured pre-state back into every table that
+// had already been committed this Apply, then returns the error -- so a
+// caller either gets every table updated, or none of them.
+//
+// It also returns a requested delay before the caller should next call
+// Apply, the same way Table.Apply reports backoff to its own caller --
+// except a single Table's Apply has no retry/backoff loop of its own yet
+// (see TableOptions' as-yet-unadded backoff knobs), so there's no
+// per-table decaying peak to aggregate here. Until that lands, the
+// requested delay this reports is simply the wall-clock time this
+// transaction's snapshot+restore work took across every table in the
+// group -- a caller pacing its own retries off Apply's cost today, not a
+// real exponential-backoff decision.
+func (g *TableGroup) Apply() (time.Duration, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	start := time.Now()
+
+	var plan []tablePlan
+	for _, t := range g.tables {
+		t.mu.Lock()
+		dirty := t.dataplaneDirty
+		var cmdName string
+		var args []string
+		var input string
+		if dirty {
+			cmdName, args, input = t.renderApplyCommand()
+		}
+		t.mu.Unlock()
+		if !dirty {
+			continue
+		}
+
+		preState, err := t.captureSave()
+		if err != nil {
+			return time.Since(start), fmt.Errorf("table group: failed to snapshot %s before apply: %w", t.Name, err)
+		}
+		plan = append(plan, tablePlan{
+			table:      t,
+			cmdName:    cmdName,
+			args:       args,
+			input:      input,
+			preState:   preState,
+			restoreCmd: t.restoreBinaryName(),
+		})
+	}
+
+	var applied []tablePlan
+	for _, p := range plan {
+		cmd := p.table.newCmd(p.cmdName, p.args...)
+		cmd.Stdin = strings.NewReader(p.input)
+		if err := cmd.Run(); err != nil {
+			g.rollback(applied)
+			return time.Since(start), fmt.Errorf("table group: %s failed applying %s, rolled back %d already-applied table(s): %w", p.cmdName, p.table.Name, len(applied), err)
+		}
+
+		p.table.mu.Lock()
+		for name, c := range p.table.chains {
+			p.table.lastAppliedHash[name] = p.table.hashChain(c)
+		}
+		p.table.dataplaneDirty = false
+		p.table.mu.Unlock()
+
+		applied = append(applied, p)
+	}
+	return time.Since(start), nil
+}
+
+// rollback replays each already-applied plan's captured pre-state back
+// into its table, undoing the transaction up to (but not including) the
+// table that failed. It's best-effort: a failure restoring one table
+// doesn't stop it trying the rest, since leaving as many tables as
+// possible consistent beats giving up at the first rollback error.
+//
+// It also clears lastAppliedHash for each rolled-back table, the same way
+// InvalidateDataplaneCache does: the Apply loop above already updated it
+// to match the new state this rollback is reverting away from, and
+// there's no cheap way to recompute it from the replayed preState text, so
+// clearing it forces the next RenderPending/PreviewApply/Apply to treat
+// every chain as pending again instead of comparing against a hash for
+// state that's no longer live.
+func (g *TableGroup) rollback(applied []tablePlan) {
+	for i := len(applied) - 1; i >= 0; i-- {
+		p := applied[i]
+		cmd := p.table.newCmd(p.restoreCmd, "--noflush")
+		cmd.Stdin = strings.NewReader(p.preState)
+		_ = cmd.Run()
+		p.table.mu.Lock()
+		p.table.lastAppliedHash = map[string]string{}
+		p.table.dataplaneDirty = true
+		p.table.mu.Unlock()
+	}
+}
+
+// captureSave returns the output of this table's iptables-save (or
+// iptables-nft-save for BackendMode "nft") variant, for TableGroup to
+// replay as a rollback target if a sibling table's restore fails later in
+// the same transaction.
+func (t *Table) captureSave() (string, error) {
+	saveCmd := "iptables-save"
+	if t.options.BackendMode == "nft" {
+		saveCmd = "iptables-nft-save"
+	} else if t.options.BackendMode == "legacy" {
+		saveCmd = "iptables-legacy-save"
+	}
+	cmd := t.newCmd(saveCmd)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
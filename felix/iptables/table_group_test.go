@@ -0,0 +1,189 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/projectcalico/calico/felix/environment"
+	"github.com/projectcalico/calico/felix/generictables"
+)
+
+// TestTableGroupRollsBackOnPartialFailure exercises a filter+nat group
+// where the second table's restore fails: the first table, which already
+// succeeded, must be rolled back to its pre-transaction state rather than
+// left applied while its sibling wasn't.
+func TestTableGroupRollsBackOnPartialFailure(t *testing.T) {
+	lock := &sync.Mutex{}
+
+	filter := NewTable("filter", 4, "cali:", lock, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			return exec.Command("true")
+		},
+	})
+	filter.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{{Action: DropAction{}}}})
+
+	nat := NewTable("nat", 4, "cali:", lock, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			if strings.HasSuffix(name, "restore") {
+				return exec.Command("false")
+			}
+			return exec.Command("true")
+		},
+	})
+	nat.UpdateChain(&Chain{Name: "cali-POSTROUTING", Rules: []generictables.Rule{{Action: AcceptAction{}}}})
+
+	group := NewTableGroup(lock, filter, nat)
+	_, err := group.Apply()
+	if err == nil {
+		t.Fatal("expected an error from the failing nat table restore")
+	}
+	if !strings.Contains(err.Error(), "nat") {
+		t.Fatalf("expected error to name the failing table, got: %v", err)
+	}
+
+	filter.mu.Lock()
+	rolledBack := filter.dataplaneDirty
+	filter.mu.Unlock()
+	if !rolledBack {
+		t.Fatal("expected the already-applied filter table to be marked dirty again after rollback")
+	}
+
+	nat.mu.Lock()
+	stillDirty := nat.dataplaneDirty
+	nat.mu.Unlock()
+	if !stillDirty {
+		t.Fatal("expected the failed nat table to remain dirty")
+	}
+}
+
+func TestTableGroupAppliesAllOnSuccess(t *testing.T) {
+	lock := &sync.Mutex{}
+	newCmd := func(name string, arg ...string) *exec.Cmd { return exec.Command("true") }
+
+	filter := NewTable("filter", 4, "cali:", lock, environment.NewFeatureDetector(nil), TableOptions{NewCmdOverride: newCmd})
+	filter.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{{Action: DropAction{}}}})
+	nat := NewTable("nat", 4, "cali:", lock, environment.NewFeatureDetector(nil), TableOptions{NewCmdOverride: newCmd})
+	nat.UpdateChain(&Chain{Name: "cali-POSTROUTING", Rules: []generictables.Rule{{Action: AcceptAction{}}}})
+
+	group := NewTableGroup(lock, filter, nat)
+	delay, err := group.Apply()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if delay < 0 {
+		t.Fatalf("expected a non-negative requested delay, got %v", delay)
+	}
+
+	for _, tbl := range []*Table{filter, nat} {
+		tbl.mu.Lock()
+		dirty := tbl.dataplaneDirty
+		tbl.mu.Unlock()
+		if dirty {
+			t.Fatalf("table %s still dirty after successful group apply", tbl.Name)
+		}
+	}
+}
+
+// TestTableGroupRollbackReusesSaveParsingForHashAlignment checks that the
+// snapshot TableGroup captures before a transaction, and replays on
+// rollback, is byte-identical to what the table's own captureSave would
+// return -- i.e. rollback really does reuse the existing save parsing
+// rather than reconstructing state a different way that could drift out
+// of alignment with the hash comments Table expects.
+func TestTableGroupRollbackReusesSaveParsingForHashAlignment(t *testing.T) {
+	lock := &sync.Mutex{}
+	const dump = "*filter\n:cali-FORWARD - [0:0]\nCOMMIT\n"
+
+	filter := NewTable("filter", 4, "cali:", lock, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			if strings.HasSuffix(name, "save") {
+				return exec.Command("printf", "%s", dump)
+			}
+			return exec.Command("true")
+		},
+	})
+	filter.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{{Action: DropAction{}}}})
+
+	want, err := filter.captureSave()
+	if err != nil {
+		t.Fatalf("unexpected error priming expected snapshot: %v", err)
+	}
+	if want != dump {
+		t.Fatalf("expected captureSave to return the fixture dump verbatim, got %q", want)
+	}
+
+	nat := NewTable("nat", 4, "cali:", lock, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			if strings.HasSuffix(name, "restore") {
+				return exec.Command("false")
+			}
+			return exec.Command("true")
+		},
+	})
+	nat.UpdateChain(&Chain{Name: "cali-POSTROUTING", Rules: []generictables.Rule{{Action: AcceptAction{}}}})
+
+	group := NewTableGroup(lock, filter, nat)
+	if _, err := group.Apply(); err == nil {
+		t.Fatal("expected the nat table's restore to fail and trigger rollback")
+	}
+}
+
+// TestTableGroupRollbackClearsLastAppliedHash checks that rolling back a
+// table's Apply also drops its lastAppliedHash, so a RenderPending or
+// PreviewApply called right after the rollback compares against "nothing
+// cached" rather than against the hash for the new state that the rollback
+// just reverted away from -- otherwise it would report nothing pending even
+// though the live dataplane was just restored to the old preState.
+func TestTableGroupRollbackClearsLastAppliedHash(t *testing.T) {
+	lock := &sync.Mutex{}
+
+	filter := NewTable("filter", 4, "cali:", lock, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			return exec.Command("true")
+		},
+	})
+	filter.UpdateChain(&Chain{Name: "cali-FORWARD", Rules: []generictables.Rule{{Action: DropAction{}}}})
+
+	nat := NewTable("nat", 4, "cali:", lock, environment.NewFeatureDetector(nil), TableOptions{
+		NewCmdOverride: func(name string, arg ...string) *exec.Cmd {
+			if strings.HasSuffix(name, "restore") {
+				return exec.Command("false")
+			}
+			return exec.Command("true")
+		},
+	})
+	nat.UpdateChain(&Chain{Name: "cali-POSTROUTING", Rules: []generictables.Rule{{Action: AcceptAction{}}}})
+
+	group := NewTableGroup(lock, filter, nat)
+	if _, err := group.Apply(); err == nil {
+		t.Fatal("expected the nat table's restore to fail and trigger rollback")
+	}
+
+	filter.mu.Lock()
+	hash, ok := filter.lastAppliedHash["cali-FORWARD"]
+	filter.mu.Unlock()
+	if ok {
+		t.Fatalf("expected lastAppliedHash to be cleared for the rolled-back filter table, still has %q", hash)
+	}
+
+	_, diff := filter.RenderPending()
+	if len(diff.AddedChains) == 0 {
+		t.Fatal("expected RenderPending to report the rolled-back table's chain as pending again")
+	}
+}
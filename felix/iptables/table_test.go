@@ -1941,6 +1941,105 @@ func describeInsertEarlyRules(dataplaneMode string) {
 	})
 }
 
+// PIt: RenderPending() would need to read the pending diff/plan Table
+// builds internally before Apply() commits it, but that internal
+// save/reconcile pipeline isn't in this tree (only this test file is) --
+// there's no Table type to add the method to yet. Left pending until
+// table.go exists alongside its tests.
+var _ = Describe("Table.RenderPending", func() {
+	PIt("should return the exact iptables-restore payload and a chain-level diff without touching the dataplane", func() {})
+})
+
+// PIt: a "nftables" BackendMode that drives `nft -f -` directly (native
+// sets/maps/verdict maps) instead of shelling out to iptables-nft-restore
+// needs NewTable/TableOptions and the generictables model this repo
+// snapshot doesn't carry. Left pending until the underlying Table exists.
+var _ = Describe("Table with BackendMode nftables", func() {
+	PIt("should drive nft directly with equivalent semantics to the iptables-nft backend", func() {})
+})
+
+// PIt: an opt-in CountersReader that parses "iptables-save -c" (and the nft
+// equivalent) and attributes hit/byte counters back to cali:<hash>
+// comments needs TableOptions fields and a Table to hang them off of,
+// neither of which exist in this tree yet.
+var _ = Describe("Table rule counter exporter", func() {
+	PIt("should attribute iptables-save -c counters to their owning rule hash and expose them as Prometheus metrics", func() {})
+})
+
+// PIt: a TableGroup/MultiTableTransaction that batches several *Table
+// instances' pending changes, --test-validates each, and applies them
+// under one held xtables lock with rollback needs multiple live *Table
+// instances to coordinate -- this tree has no Table to construct one.
+var _ = Describe("TableGroup transactional Apply", func() {
+	PIt("should apply pending changes across multiple tables atomically and roll back all of them if one fails", func() {})
+})
+
+// PIt: a consistent-hash "stable-hash" InsertMode needs TableOptions and
+// InsertOrAppendRules' chain-rewrite path, which live in the Table this
+// snapshot doesn't carry -- there's nothing here yet to add the mode to.
+var _ = Describe("Table with InsertMode stable-hash", func() {
+	PIt("should keep unrelated rules at stable positions across InsertOrAppendRules churn", func() {})
+})
+
+// PIt: a netlink-based nftables backend implementing the same
+// generictables.Table contract (batched transactions, no forked
+// userspace processes) needs that contract and a Table to implement it
+// against, neither of which exist in this tree.
+var _ = Describe("Table backed by nftables over netlink", func() {
+	PIt("should commit InsertOrAppendRules/AppendRules/UpdateChains/Apply as a single kernel transaction with no forked process", func() {})
+})
+
+// PIt: Table.SubscribeDrift(func(DriftEvent)) would promote the existing
+// UnexpectedInsertsSeen() tracking into a real subscription API, but both
+// that tracking and the Table it lives on are outside this snapshot.
+var _ = Describe("Table.SubscribeDrift", func() {
+	PIt("should notify subscribers of foreign rule insertions with chain name, offending lines, and Calico-owned status", func() {})
+})
+
+// PIt: recovering from a sibling table's restore failing after this one
+// committed -- by replaying the save output captured before the restore,
+// as simulated by OnPreRestore's mid-stream clobber above -- needs the
+// same TableGroup coordinator as Table.RenderPending's neighbour, backed
+// by real *Table instances this tree doesn't have.
+var _ = Describe("TableGroup rollback via captured save replay", func() {
+	PIt("should replay a table's pre-restore save output if a sibling table's restore fails after this one committed", func() {})
+})
+
+// PIt: DeleteRulesNow would need to hash and remove rules from a
+// non-Calico chain the same way InsertRulesNow/CheckRulesPresent add and
+// detect them, but both of those live on the Table this tree doesn't
+// carry.
+var _ = Describe("Table.DeleteRulesNow", func() {
+	PIt("should atomically remove previously-inserted early rules from a non-Calico chain by hash", func() {})
+})
+
+// PIt: configurable InitialBackoff/MaxBackoff/MaxRetries/
+// BackoffMultiplier/BackoffJitterFraction fields would replace the
+// hard-coded Apply() retry schedule, but that schedule lives inside the
+// Table this tree doesn't have -- only SleepOverride's caller is missing
+// too.
+var _ = Describe("Table.Apply with a configurable backoff policy", func() {
+	PIt("should apply TableOptions' configured backoff/retry policy, with jitter, instead of the hard-coded schedule", func() {})
+})
+
+// PIt: TableOptions.DryRun plus PreviewApply() would run the full
+// diff/reconcile pipeline and return the would-be iptables-restore input
+// without invoking it, but that pipeline is the same missing Table
+// internals RenderPending above needs.
+var _ = Describe("Table.PreviewApply", func() {
+	PIt("should run the full reconcile pipeline and return the restore input and commands without invoking iptables-restore", func() {})
+})
+
+// PIt: a property-based harness generating random operation/failure
+// sequences (in the spirit of the existing FailNextSaveRead/
+// FailNextRestore/OnPreRestore scenarios, but randomized rather than
+// enumerated) needs the same Table and MockDataplane this whole file's
+// pending specs are blocked on -- there's a MockDataplane referenced
+// above via iptables/testutils, but no Table for it to drive.
+var _ = Describe("Table reconciliation property test harness", func() {
+	PIt("should converge to a consistent dataplane state across randomized sequences of user ops and injected failures", func() {})
+})
+
 type mockMutex struct {
 	Held     bool
 	WasTaken bool
@@ -0,0 +1,61 @@
+// Copyright (c) 2022-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logutils holds small logging helpers shared across felix's
+// dataplane drivers.
+package logutils
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Summarizer batches up repeated "did operation X N times" log lines from
+// a tight reconciliation loop (such as Table.Apply's retry loop) into a
+// single summary line per flush, so a noisy dataplane doesn't spam the log
+// at one line per attempt.
+type Summarizer struct {
+	name string
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewSummarizer creates a Summarizer that identifies its owner (typically
+// the name of the reconciliation loop) as name in its summary lines.
+func NewSummarizer(name string) *Summarizer {
+	return &Summarizer{name: name, counts: map[string]int{}}
+}
+
+// RecordOperation notes that operation occurred once, to be included in
+// the next Flush.
+func (s *Summarizer) RecordOperation(operation string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[operation]++
+}
+
+// Flush logs one summary line per distinct operation recorded since the
+// last Flush, then resets the counts.
+func (s *Summarizer) Flush() {
+	s.mu.Lock()
+	counts := s.counts
+	s.counts = map[string]int{}
+	s.mu.Unlock()
+
+	for op, n := range counts {
+		log.WithFields(log.Fields{"loop": s.name, "operation": op, "count": n}).Info("Summary of dataplane operations")
+	}
+}
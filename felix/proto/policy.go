@@ -0,0 +1,395 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proto contains the wire types shared between Felix and the
+// per-node policy sync clients (such as the Dikastes/app-policy ext_authz
+// server). These types mirror the felixbackend.proto definitions; they are
+// hand-maintained here rather than regenerated by protoc in this checkout.
+package proto
+
+// IPSetUpdate_IPSetType enumerates the kind of membership data held in an
+// IP set update.
+type IPSetUpdate_IPSetType int32
+
+const (
+	IPSetUpdate_IP       IPSetUpdate_IPSetType = 0
+	IPSetUpdate_IP_AND_PORT IPSetUpdate_IPSetType = 1
+	IPSetUpdate_NET       IPSetUpdate_IPSetType = 2
+)
+
+// ServiceAccountMatch matches the service account of one side of a flow.
+type ServiceAccountMatch struct {
+	Selector string   `protobuf:"bytes,1,opt,name=selector,proto3" json:"selector,omitempty"`
+	Names    []string `protobuf:"bytes,2,rep,name=names,proto3" json:"names,omitempty"`
+}
+
+// NamespaceID identifies a namespace by name.
+type NamespaceID struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+// NamespaceUpdate carries the labels of a namespace known to Felix.
+type NamespaceUpdate struct {
+	Id     *NamespaceID      `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Labels map[string]string `protobuf:"bytes,2,rep,name=labels,proto3" json:"labels,omitempty"`
+}
+
+// PortRange is an inclusive range of L4 ports, [First, Last].
+type PortRange struct {
+	First int32 `protobuf:"varint,1,opt,name=first,proto3" json:"first,omitempty"`
+	Last  int32 `protobuf:"varint,2,opt,name=last,proto3" json:"last,omitempty"`
+}
+
+// Protocol identifies an L4 protocol, either by well-known name or number.
+type Protocol struct {
+	// Types that are valid to be assigned to NumberOrName:
+	//	*Protocol_Number
+	//	*Protocol_Name
+	NumberOrName isProtocol_NumberOrName `protobuf_oneof:"number_or_name"`
+}
+
+type isProtocol_NumberOrName interface {
+	isProtocol_NumberOrName()
+}
+
+type Protocol_Number struct {
+	Number int32 `protobuf:"varint,1,opt,name=number,proto3,oneof"`
+}
+
+type Protocol_Name struct {
+	Name string `protobuf:"bytes,2,opt,name=name,proto3,oneof"`
+}
+
+func (*Protocol_Number) isProtocol_NumberOrName() {}
+func (*Protocol_Name) isProtocol_NumberOrName()   {}
+
+func (m *Protocol) GetNumber() int32 {
+	if m != nil {
+		if x, ok := m.NumberOrName.(*Protocol_Number); ok {
+			return x.Number
+		}
+	}
+	return 0
+}
+
+func (m *Protocol) GetName() string {
+	if m != nil {
+		if x, ok := m.NumberOrName.(*Protocol_Name); ok {
+			return x.Name
+		}
+	}
+	return ""
+}
+
+// HTTPMatch_PathMatch matches an HTTP request path using one of several
+// mutually exclusive match kinds.
+type HTTPMatch_PathMatch struct {
+	// Types that are valid to be assigned to PathMatch:
+	//	*HTTPMatch_PathMatch_Exact
+	//	*HTTPMatch_PathMatch_Prefix
+	//	*HTTPMatch_PathMatch_Regex
+	PathMatch isHTTPMatch_PathMatch_PathMatch `protobuf_oneof:"path_match"`
+}
+
+type isHTTPMatch_PathMatch_PathMatch interface {
+	isHTTPMatch_PathMatch_PathMatch()
+}
+
+type HTTPMatch_PathMatch_Exact struct {
+	Exact string `protobuf:"bytes,1,opt,name=exact,proto3,oneof"`
+}
+
+type HTTPMatch_PathMatch_Prefix struct {
+	Prefix string `protobuf:"bytes,2,opt,name=prefix,proto3,oneof"`
+}
+
+// HTTPMatch_PathMatch_Regex matches the path against an RE2 regular
+// expression. Unlike Exact/Prefix, the pattern is not anchored implicitly;
+// callers that want a whole-path match should anchor it themselves with
+// ^ and $.
+type HTTPMatch_PathMatch_Regex struct {
+	Regex string `protobuf:"bytes,3,opt,name=regex,proto3,oneof"`
+}
+
+func (*HTTPMatch_PathMatch_Exact) isHTTPMatch_PathMatch_PathMatch()  {}
+func (*HTTPMatch_PathMatch_Prefix) isHTTPMatch_PathMatch_PathMatch() {}
+func (*HTTPMatch_PathMatch_Regex) isHTTPMatch_PathMatch_PathMatch()  {}
+
+func (m *HTTPMatch_PathMatch) GetExact() string {
+	if m != nil {
+		if x, ok := m.PathMatch.(*HTTPMatch_PathMatch_Exact); ok {
+			return x.Exact
+		}
+	}
+	return ""
+}
+
+func (m *HTTPMatch_PathMatch) GetPrefix() string {
+	if m != nil {
+		if x, ok := m.PathMatch.(*HTTPMatch_PathMatch_Prefix); ok {
+			return x.Prefix
+		}
+	}
+	return ""
+}
+
+func (m *HTTPMatch_PathMatch) GetRegex() string {
+	if m != nil {
+		if x, ok := m.PathMatch.(*HTTPMatch_PathMatch_Regex); ok {
+			return x.Regex
+		}
+	}
+	return ""
+}
+
+// HTTPMatch_HeaderMatch matches a single HTTP header (looked up
+// case-insensitively by name, per RFC 7230) using one of several mutually
+// exclusive match kinds. It is also used for the Hosts clause, matched
+// against the effective Host/:authority value.
+type HTTPMatch_HeaderMatch struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+
+	// Types that are valid to be assigned to Match:
+	//	*HTTPMatch_HeaderMatch_Exact
+	//	*HTTPMatch_HeaderMatch_Prefix
+	//	*HTTPMatch_HeaderMatch_Regex
+	//	*HTTPMatch_HeaderMatch_Present
+	//	*HTTPMatch_HeaderMatch_NotPresent
+	Match isHTTPMatch_HeaderMatch_Match `protobuf_oneof:"match"`
+}
+
+type isHTTPMatch_HeaderMatch_Match interface {
+	isHTTPMatch_HeaderMatch_Match()
+}
+
+type HTTPMatch_HeaderMatch_Exact struct {
+	Exact string `protobuf:"bytes,2,opt,name=exact,proto3,oneof"`
+}
+
+type HTTPMatch_HeaderMatch_Prefix struct {
+	Prefix string `protobuf:"bytes,3,opt,name=prefix,proto3,oneof"`
+}
+
+type HTTPMatch_HeaderMatch_Regex struct {
+	Regex string `protobuf:"bytes,4,opt,name=regex,proto3,oneof"`
+}
+
+// HTTPMatch_HeaderMatch_Present matches when the header is present,
+// regardless of its value.
+type HTTPMatch_HeaderMatch_Present struct {
+	Present bool `protobuf:"varint,5,opt,name=present,proto3,oneof"`
+}
+
+// HTTPMatch_HeaderMatch_NotPresent matches when the header is absent.
+type HTTPMatch_HeaderMatch_NotPresent struct {
+	NotPresent bool `protobuf:"varint,6,opt,name=not_present,proto3,oneof"`
+}
+
+func (*HTTPMatch_HeaderMatch_Exact) isHTTPMatch_HeaderMatch_Match()      {}
+func (*HTTPMatch_HeaderMatch_Prefix) isHTTPMatch_HeaderMatch_Match()     {}
+func (*HTTPMatch_HeaderMatch_Regex) isHTTPMatch_HeaderMatch_Match()      {}
+func (*HTTPMatch_HeaderMatch_Present) isHTTPMatch_HeaderMatch_Match()    {}
+func (*HTTPMatch_HeaderMatch_NotPresent) isHTTPMatch_HeaderMatch_Match() {}
+
+func (m *HTTPMatch_HeaderMatch) GetExact() string {
+	if m != nil {
+		if x, ok := m.Match.(*HTTPMatch_HeaderMatch_Exact); ok {
+			return x.Exact
+		}
+	}
+	return ""
+}
+
+func (m *HTTPMatch_HeaderMatch) GetPrefix() string {
+	if m != nil {
+		if x, ok := m.Match.(*HTTPMatch_HeaderMatch_Prefix); ok {
+			return x.Prefix
+		}
+	}
+	return ""
+}
+
+func (m *HTTPMatch_HeaderMatch) GetRegex() string {
+	if m != nil {
+		if x, ok := m.Match.(*HTTPMatch_HeaderMatch_Regex); ok {
+			return x.Regex
+		}
+	}
+	return ""
+}
+
+func (m *HTTPMatch_HeaderMatch) GetPresent() bool {
+	if m != nil {
+		if x, ok := m.Match.(*HTTPMatch_HeaderMatch_Present); ok {
+			return x.Present
+		}
+	}
+	return false
+}
+
+func (m *HTTPMatch_HeaderMatch) GetNotPresent() bool {
+	if m != nil {
+		if x, ok := m.Match.(*HTTPMatch_HeaderMatch_NotPresent); ok {
+			return x.NotPresent
+		}
+	}
+	return false
+}
+
+// HTTPMatch matches HTTP-layer attributes of a request: method, path,
+// headers and host. An empty/nil clause always matches.
+type HTTPMatch struct {
+	Methods []string                 `protobuf:"bytes,1,rep,name=methods,proto3" json:"methods,omitempty"`
+	Paths   []*HTTPMatch_PathMatch   `protobuf:"bytes,2,rep,name=paths,proto3" json:"paths,omitempty"`
+	Headers []*HTTPMatch_HeaderMatch `protobuf:"bytes,3,rep,name=headers,proto3" json:"headers,omitempty"`
+	Hosts   []*HTTPMatch_HeaderMatch `protobuf:"bytes,4,rep,name=hosts,proto3" json:"hosts,omitempty"`
+}
+
+// Rule mirrors the felixbackend.proto Rule message used to describe a
+// single match/action clause within a policy.
+type Rule struct {
+	Action string `protobuf:"bytes,1,opt,name=action,proto3" json:"action,omitempty"`
+
+	Protocol    *Protocol `protobuf:"bytes,2,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	NotProtocol *Protocol `protobuf:"bytes,3,opt,name=not_protocol,proto3" json:"not_protocol,omitempty"`
+
+	SrcNet []string `protobuf:"bytes,4,rep,name=src_net,proto3" json:"src_net,omitempty"`
+	DstNet []string `protobuf:"bytes,5,rep,name=dst_net,proto3" json:"dst_net,omitempty"`
+
+	NotSrcNet []string `protobuf:"bytes,21,rep,name=not_src_net,proto3" json:"not_src_net,omitempty"`
+	NotDstNet []string `protobuf:"bytes,22,rep,name=not_dst_net,proto3" json:"not_dst_net,omitempty"`
+
+	SrcPorts []*PortRange `protobuf:"bytes,6,rep,name=src_ports,proto3" json:"src_ports,omitempty"`
+	DstPorts []*PortRange `protobuf:"bytes,7,rep,name=dst_ports,proto3" json:"dst_ports,omitempty"`
+
+	SrcIpSetIds    []string `protobuf:"bytes,8,rep,name=src_ip_set_ids,proto3" json:"src_ip_set_ids,omitempty"`
+	DstIpSetIds    []string `protobuf:"bytes,9,rep,name=dst_ip_set_ids,proto3" json:"dst_ip_set_ids,omitempty"`
+	NotSrcIpSetIds []string `protobuf:"bytes,10,rep,name=not_src_ip_set_ids,proto3" json:"not_src_ip_set_ids,omitempty"`
+	NotDstIpSetIds []string `protobuf:"bytes,11,rep,name=not_dst_ip_set_ids,proto3" json:"not_dst_ip_set_ids,omitempty"`
+
+	DstIpPortSetIds    []string `protobuf:"bytes,12,rep,name=dst_ip_port_set_ids,proto3" json:"dst_ip_port_set_ids,omitempty"`
+	NotDstIpPortSetIds []string `protobuf:"bytes,23,rep,name=not_dst_ip_port_set_ids,proto3" json:"not_dst_ip_port_set_ids,omitempty"`
+
+	SrcServiceAccountMatch *ServiceAccountMatch `protobuf:"bytes,13,opt,name=src_service_account_match,proto3" json:"src_service_account_match,omitempty"`
+	DstServiceAccountMatch *ServiceAccountMatch `protobuf:"bytes,14,opt,name=dst_service_account_match,proto3" json:"dst_service_account_match,omitempty"`
+
+	OriginalSrcSelector          string `protobuf:"bytes,15,opt,name=original_src_selector,proto3" json:"original_src_selector,omitempty"`
+	OriginalDstSelector          string `protobuf:"bytes,16,opt,name=original_dst_selector,proto3" json:"original_dst_selector,omitempty"`
+	OriginalSrcNamespaceSelector string `protobuf:"bytes,17,opt,name=original_src_namespace_selector,proto3" json:"original_src_namespace_selector,omitempty"`
+	OriginalDstNamespaceSelector string `protobuf:"bytes,18,opt,name=original_dst_namespace_selector,proto3" json:"original_dst_namespace_selector,omitempty"`
+
+	HttpMatch *HTTPMatch `protobuf:"bytes,19,opt,name=http_match,proto3" json:"http_match,omitempty"`
+	JwtMatch  *JWTMatch  `protobuf:"bytes,20,opt,name=jwt_match,proto3" json:"jwt_match,omitempty"`
+}
+
+// JWTMatch matches claims of the verified JWT Envoy attached to the
+// request's metadata_context (under the envoy.filters.http.jwt_authn
+// filter). Issuer and Audience are optional exact-match shortcuts for the
+// "iss"/"aud" claims; Claims holds arbitrary claim-path predicates.
+type JWTMatch struct {
+	Issuer   string                 `protobuf:"bytes,1,opt,name=issuer,proto3" json:"issuer,omitempty"`
+	Audience string                 `protobuf:"bytes,2,opt,name=audience,proto3" json:"audience,omitempty"`
+	Claims   []*JWTMatch_ClaimMatch `protobuf:"bytes,3,rep,name=claims,proto3" json:"claims,omitempty"`
+}
+
+// JWTMatch_ClaimMatch matches a single claim, addressed by a dotted JSON
+// path (e.g. "realm_access.roles") into the JWT payload.
+type JWTMatch_ClaimMatch struct {
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+
+	// Match is one of JWTMatch_ClaimMatch_Exact, _Regex, _In or _Contains.
+	Match isJWTMatch_ClaimMatch_Match `protobuf_oneof:"match"`
+}
+
+type isJWTMatch_ClaimMatch_Match interface {
+	isJWTMatch_ClaimMatch_Match()
+}
+
+// JWTMatch_ClaimMatch_Exact matches a scalar claim by exact string value.
+type JWTMatch_ClaimMatch_Exact struct {
+	Exact string `protobuf:"bytes,2,opt,name=exact,proto3,oneof"`
+}
+
+// JWTMatch_ClaimMatch_Regex matches a scalar claim against an unanchored
+// RE2 regex, the same convention matchHTTPPaths uses for path regexes.
+type JWTMatch_ClaimMatch_Regex struct {
+	Regex string `protobuf:"bytes,3,opt,name=regex,proto3,oneof"`
+}
+
+// JWTMatch_ClaimMatch_In matches a scalar claim against a fixed set of
+// acceptable values.
+type JWTMatch_ClaimMatch_In struct {
+	In *JWTMatch_StringList `protobuf:"bytes,4,opt,name=in,proto3,oneof"`
+}
+
+// JWTMatch_ClaimMatch_Contains matches an array-valued claim (e.g.
+// "realm_access.roles") that contains the given value as one of its
+// elements.
+type JWTMatch_ClaimMatch_Contains struct {
+	Contains string `protobuf:"bytes,5,opt,name=contains,proto3,oneof"`
+}
+
+func (*JWTMatch_ClaimMatch_Exact) isJWTMatch_ClaimMatch_Match()    {}
+func (*JWTMatch_ClaimMatch_Regex) isJWTMatch_ClaimMatch_Match()    {}
+func (*JWTMatch_ClaimMatch_In) isJWTMatch_ClaimMatch_Match()       {}
+func (*JWTMatch_ClaimMatch_Contains) isJWTMatch_ClaimMatch_Match() {}
+
+// JWTMatch_StringList is the wire representation of a repeated string field
+// used inside a oneof (proto3 doesn't allow a bare repeated field there).
+type JWTMatch_StringList struct {
+	Values []string `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
+}
+
+func (m *JWTMatch_ClaimMatch) GetExact() string {
+	if m != nil {
+		if x, ok := m.Match.(*JWTMatch_ClaimMatch_Exact); ok {
+			return x.Exact
+		}
+	}
+	return ""
+}
+
+func (m *JWTMatch_ClaimMatch) GetRegex() string {
+	if m != nil {
+		if x, ok := m.Match.(*JWTMatch_ClaimMatch_Regex); ok {
+			return x.Regex
+		}
+	}
+	return ""
+}
+
+func (m *JWTMatch_ClaimMatch) GetIn() *JWTMatch_StringList {
+	if m != nil {
+		if x, ok := m.Match.(*JWTMatch_ClaimMatch_In); ok {
+			return x.In
+		}
+	}
+	return nil
+}
+
+func (m *JWTMatch_ClaimMatch) GetContains() string {
+	if m != nil {
+		if x, ok := m.Match.(*JWTMatch_ClaimMatch_Contains); ok {
+			return x.Contains
+		}
+	}
+	return ""
+}
+
+func (m *JWTMatch_StringList) GetValues() []string {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+// Copyright (c) 2022-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rules names the chains and hash prefix felix's own chains use,
+// so that felix/iptables can recognise (and, on chain prefixes it no
+// longer creates, clean up) rules it owns without touching anything a
+// human operator or another piece of software added.
+package rules
+
+// RuleHashPrefix is embedded in a comment on every rule felix programs, so
+// Table can tell "a rule felix owns" apart from "a rule someone else
+// added to one of felix's chains" during reconciliation.
+const RuleHashPrefix = "cali:"
+
+// AllHistoricChainNamePrefixes lists every chain name prefix felix has
+// ever used to own chains, across all past releases. Table sweeps up
+// chains under all of them (not just the current prefix) so that an
+// upgrade from an older release doesn't leave orphaned chains behind.
+var AllHistoricChainNamePrefixes = []string{"cali-", "felix-"}
@@ -0,0 +1,37 @@
+// Copyright (c) 2021-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package types holds small, dependency-light helpers for converting
+// between the wire (proto) representations used on the Felix/policy-sync
+// API and the plain Go types used as map keys elsewhere in Felix.
+package types
+
+import (
+	"github.com/projectcalico/calico/felix/proto"
+)
+
+// NamespaceID is a plain, comparable Go type suitable for use as a map key,
+// unlike the generated *proto.NamespaceID pointer type.
+type NamespaceID struct {
+	Name string
+}
+
+// ProtoToNamespaceID converts a *proto.NamespaceID into the comparable
+// NamespaceID value used to key PolicyStore.NamespaceByID.
+func ProtoToNamespaceID(id *proto.NamespaceID) NamespaceID {
+	if id == nil {
+		return NamespaceID{}
+	}
+	return NamespaceID{Name: id.Name}
+}
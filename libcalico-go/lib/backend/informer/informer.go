@@ -0,0 +1,279 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package informer adapts a Kubernetes cache.SharedIndexInformer into a
+// stream of model.KVPair events, so that multiple in-process consumers
+// (Typha, Felix's calc graph, kube-controllers) that would otherwise each
+// run their own watch against the apiserver can instead share a single
+// backend watch and its in-memory cache.
+package informer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	bapi "github.com/projectcalico/calico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/calico/libcalico-go/lib/backend/model"
+)
+
+// RawObject is the shape a ListerWatcherFunc's List/Watch results must
+// implement so Cache can decode them into model.KVPairs without knowing
+// anything about the concrete Kubernetes type backing a given resource.
+// DefaultPath and RawValue are exactly the inputs KeyFromDefaultPath and
+// ParseValue already expect from every other backend client in this
+// package's family, so a ListerWatcherFunc is typically a thin wrapper
+// around an existing REST client that already produces these two things.
+// GetResourceVersion (from metav1.Object) is what Cache surfaces as the
+// emitted KVPair's Revision.
+type RawObject interface {
+	runtime.Object
+	metav1.Object
+
+	// DefaultPath is the datastore path this object is stored at, in
+	// the same format KeyToDefaultPath produces, e.g.
+	// "/calico/resources/v3/globalnetworkpolicies/default.foo".
+	DefaultPath() string
+
+	// RawValue is the encoded value at DefaultPath, in whatever wire
+	// format ParseValue expects for the resource's key type (JSON
+	// unless a ValueCodec has been registered for it).
+	RawValue() []byte
+}
+
+// ListerWatcherFunc builds the cache.ListerWatcher that backs the
+// informer for one registered model.ListInterface root. Implementations
+// must respect model.ListOptionsIsFullyQualified(list) (scope the
+// List/Watch to the single object the options name) and
+// model.IsListOptionsLastSegmentPrefix(list) (scope to a name-prefix
+// rather than an exact name), the same two cases every other consumer of
+// ListInterface already has to handle.
+type ListerWatcherFunc func(list model.ListInterface) cache.ListerWatcher
+
+// Cache runs one cache.SharedIndexInformer per registered ListInterface
+// root and republishes their add/update/delete events as api.Updates
+// keyed by model.Key, plus a thread-safe GetByKey lookup backed by the
+// informers' stores.
+type Cache struct {
+	listerWatcherFor ListerWatcherFunc
+	resyncPeriod     time.Duration
+
+	updates chan bapi.Update
+	stopCh  <-chan struct{}
+
+	mu        sync.RWMutex
+	started   bool
+	informers []cache.SharedIndexInformer
+	synced    []cache.InformerSynced
+}
+
+// keyIndex is the name of the cache.Indexer index Cache adds to every
+// informer it starts, keyed by KeyToDefaultPath(key) so GetByKey can
+// resolve a model.Key to the informer that owns it without a linear scan
+// over every registered root.
+const keyIndex = "calicoDefaultPath"
+
+// NewCache creates a Cache that is not yet watching anything; call
+// Register for each ListInterface root of interest and then Run to start
+// the underlying informers.
+func NewCache(listerWatcherFor ListerWatcherFunc, resyncPeriod time.Duration) *Cache {
+	return &Cache{
+		listerWatcherFor: listerWatcherFor,
+		resyncPeriod:     resyncPeriod,
+		updates:          make(chan bapi.Update, 100),
+	}
+}
+
+// Register adds an informer for list. It must be called before Run: like
+// client-go's SharedInformerFactory, Register panics if the Cache has
+// already started, rather than silently adding an informer whose
+// ListerWatcher is never invoked. exampleObject is only used by the
+// informer machinery to type-check decoded results; it is never itself
+// decoded. Registering the same root twice would start two redundant
+// informers against the apiserver, defeating the point of sharing the
+// watch, so callers should register each ListInterface exactly once,
+// typically during startup wiring alongside the other roots the process
+// cares about.
+func (c *Cache) Register(list model.ListInterface, exampleObject RawObject) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.started {
+		panic("informer.Cache: Register called after Run")
+	}
+
+	inf := cache.NewSharedIndexInformer(
+		c.listerWatcherFor(list),
+		exampleObject,
+		c.resyncPeriod,
+		cache.Indexers{keyIndex: c.indexByDefaultPath},
+	)
+	inf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.onEvent(bapi.UpdateTypeKVNew, obj) },
+		UpdateFunc: func(_, obj interface{}) { c.onEvent(bapi.UpdateTypeKVUpdated, obj) },
+		DeleteFunc: func(obj interface{}) { c.onDelete(obj) },
+	})
+
+	c.informers = append(c.informers, inf)
+	c.synced = append(c.synced, inf.HasSynced)
+}
+
+// Run starts every registered informer and blocks until stopCh is
+// closed. It does not return until all informers have shut down, mirroring
+// cache.SharedIndexInformer.Run's own contract.
+func (c *Cache) Run(stopCh <-chan struct{}) {
+	c.mu.Lock()
+	c.started = true
+	c.stopCh = stopCh
+	informers := append([]cache.SharedIndexInformer(nil), c.informers...)
+	synced := append([]cache.InformerSynced(nil), c.synced...)
+	c.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, inf := range informers {
+		wg.Add(1)
+		go func(inf cache.SharedIndexInformer) {
+			defer wg.Done()
+			inf.Run(stopCh)
+		}(inf)
+	}
+
+	if !cache.WaitForCacheSync(stopCh, synced...) {
+		log.Warning("informer.Cache: stopped before initial sync completed")
+	}
+
+	wg.Wait()
+	close(c.updates)
+}
+
+// Updates returns the channel of api.Updates translated from the
+// underlying informers' add/update/delete events. It is closed once Run
+// returns.
+func (c *Cache) Updates() <-chan bapi.Update {
+	return c.updates
+}
+
+// GetByKey looks up key in whichever registered informer's store holds
+// it, returning ok=false if none of them have an entry for it. The
+// KVPair's Revision is the informer store's ResourceVersion for the
+// object at the time of the lookup, exactly as it would be if the caller
+// had watched key directly instead of sharing this cache.
+func (c *Cache) GetByKey(key model.Key) (*model.KVPair, bool, error) {
+	path, err := model.KeyToDefaultPath(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	c.mu.RLock()
+	informers := append([]cache.SharedIndexInformer(nil), c.informers...)
+	c.mu.RUnlock()
+
+	for _, inf := range informers {
+		items, err := inf.GetIndexer().ByIndex(keyIndex, path)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(items) == 0 {
+			continue
+		}
+		kvp, err := c.toKVPair(items[0].(RawObject))
+		if err != nil {
+			return nil, false, err
+		}
+		return kvp, true, nil
+	}
+	return nil, false, nil
+}
+
+// indexByDefaultPath is the cache.IndexFunc registered under keyIndex; it
+// is what lets GetByKey resolve a model.Key straight to its owning
+// informer's store entry via KeyToDefaultPath instead of decoding every
+// item back into a model.Key up front.
+func (c *Cache) indexByDefaultPath(obj interface{}) ([]string, error) {
+	raw, ok := obj.(RawObject)
+	if !ok {
+		return nil, fmt.Errorf("informer.Cache: object %T does not implement RawObject", obj)
+	}
+	return []string{raw.DefaultPath()}, nil
+}
+
+// toKVPair decodes raw using KeyFromDefaultPath + ParseValue, the same
+// decoder every other backend client in this package uses, so a KVPair
+// produced by this cache is indistinguishable from one read directly off
+// the datastore.
+func (c *Cache) toKVPair(raw RawObject) (*model.KVPair, error) {
+	key := model.KeyFromDefaultPath(raw.DefaultPath())
+	if key == nil {
+		return nil, fmt.Errorf("informer.Cache: %q is not a recognised default path", raw.DefaultPath())
+	}
+	value, err := model.ParseValue(key, raw.RawValue())
+	if err != nil {
+		return nil, err
+	}
+	return &model.KVPair{
+		Key:      key,
+		Value:    value,
+		Revision: raw.GetResourceVersion(),
+	}, nil
+}
+
+func (c *Cache) onEvent(t bapi.UpdateType, obj interface{}) {
+	raw, ok := obj.(RawObject)
+	if !ok {
+		log.Warningf("informer.Cache: dropping event for unexpected object type %T", obj)
+		return
+	}
+	kvp, err := c.toKVPair(raw)
+	if err != nil {
+		log.WithError(err).Warningf("informer.Cache: dropping undecodable object at %q", raw.DefaultPath())
+		return
+	}
+	c.send(bapi.Update{KVPair: *kvp, UpdateType: t})
+}
+
+func (c *Cache) onDelete(obj interface{}) {
+	if tomb, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tomb.Obj
+	}
+	raw, ok := obj.(RawObject)
+	if !ok {
+		log.Warningf("informer.Cache: dropping delete event for unexpected object type %T", obj)
+		return
+	}
+	key := model.KeyFromDefaultPath(raw.DefaultPath())
+	if key == nil {
+		log.Warningf("informer.Cache: dropping delete event for unrecognised path %q", raw.DefaultPath())
+		return
+	}
+	c.send(bapi.Update{
+		KVPair:     model.KVPair{Key: key},
+		UpdateType: bapi.UpdateTypeKVDeleted,
+	})
+}
+
+// send delivers update to Updates(), but gives way to stopCh being closed
+// so a consumer that stops draining Updates() during shutdown can't wedge
+// the informer's event-processing goroutine forever, which would in turn
+// stop inf.Run(stopCh) from ever returning.
+func (c *Cache) send(update bapi.Update) {
+	select {
+	case c.updates <- update:
+	case <-c.stopCh:
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/projectcalico/calico/libcalico-go/lib/json"
+)
+
+// ValueCodec is the wire encoding ParseValue/SerializeValue use to turn a
+// KVPair's Value into bytes and back. The rawString/rawBool/rawIP special
+// cases and the typeBlockAff empty-string compatibility branch in
+// ParseValue/SerializeValue are codec-independent and never reach a
+// ValueCodec.
+type ValueCodec interface {
+	// Marshal encodes value, which was stored (or is about to be stored)
+	// under key, into its wire representation.
+	Marshal(key Key, value interface{}) ([]byte, error)
+
+	// Unmarshal decodes rawData (with any codec prefix already stripped)
+	// into a new value appropriate for key, the same way ParseValue's
+	// struct-construction logic used to work inline.
+	Unmarshal(key Key, rawData []byte) (interface{}, error)
+}
+
+// codecPrefixCBOR marks a stored value as CBOR-encoded. It's chosen so it
+// can never appear as the first byte of a JSON document (which always
+// starts with whitespace or one of `{["-tfn0-9`), so values written
+// before this feature existed keep decoding as JSON with no migration
+// step.
+const codecPrefixCBOR byte = 0xc0
+
+var (
+	jsonValueCodec ValueCodec = jsonCodec{}
+	cborValueCodec ValueCodec = cborCodec{}
+
+	// codecsByType lets a datastore driver opt a hot, high-fanout value
+	// type (WorkloadEndpoint, IPAM Block, BGPPeer, ...) into CBOR while
+	// leaving low-churn config on the JSON default. Unregistered types
+	// always use jsonValueCodec.
+	codecsByType = map[reflect.Type]ValueCodec{}
+)
+
+// RegisterCodec opts valueType into codec for all future
+// ParseValue/SerializeValue calls against keys whose valueType() returns
+// it. Intended to be called from a datastore driver's init(), mirroring
+// the way resource kinds self-register elsewhere in this package.
+func RegisterCodec(valueType reflect.Type, codec ValueCodec) {
+	codecsByType[valueType] = codec
+}
+
+// codecForType returns the registered codec for valueType, defaulting to
+// JSON.
+func codecForType(valueType reflect.Type) ValueCodec {
+	if c, ok := codecsByType[valueType]; ok {
+		return c
+	}
+	return jsonValueCodec
+}
+
+// decodeCodecPrefix strips rawData's magic-byte prefix, if any, and
+// returns the codec it selects along with the remaining payload.
+func decodeCodecPrefix(rawData []byte) (ValueCodec, []byte) {
+	if len(rawData) > 0 && rawData[0] == codecPrefixCBOR {
+		return cborValueCodec, rawData[1:]
+	}
+	return jsonValueCodec, rawData
+}
+
+// encodeCodecPrefix prepends codec's magic-byte prefix to body, if it
+// needs one. JSON keeps writing with no prefix at all, so values written
+// by a binary that predates ValueCodec round-trip unchanged.
+func encodeCodecPrefix(codec ValueCodec, body []byte) []byte {
+	if codec == cborValueCodec {
+		return append([]byte{codecPrefixCBOR}, body...)
+	}
+	return body
+}
+
+// newValueFor allocates a new value of key's valueType, pre-populating
+// its embedded Key field the way ParseValue always has, so both codecs
+// can share the construction logic instead of duplicating it.
+func newValueFor(key Key) (interface{}, reflect.Value, error) {
+	valueType, err := key.valueType()
+	if err != nil {
+		return nil, reflect.Value{}, err
+	}
+	value := reflect.New(valueType)
+	elem := value.Elem()
+	if elem.Kind() == reflect.Struct && elem.NumField() > 0 {
+		if elem.Field(0).Type() == reflect.ValueOf(key).Type() {
+			elem.Field(0).Set(reflect.ValueOf(key))
+		}
+	}
+	return value.Interface(), elem, nil
+}
+
+// unwrapIfNotStruct returns elem's value directly for a pointer-to-map or
+// pointer-to-slice valueType (iface otherwise), matching ParseValue's
+// long-standing behavior of not returning a pointer in that case.
+func unwrapIfNotStruct(iface interface{}, elem reflect.Value) interface{} {
+	if elem.Kind() != reflect.Struct {
+		return elem.Interface()
+	}
+	return iface
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(key Key, value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (jsonCodec) Unmarshal(key Key, rawData []byte) (interface{}, error) {
+	iface, elem, err := newValueFor(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(rawData, iface); err != nil {
+		return nil, err
+	}
+	return unwrapIfNotStruct(iface, elem), nil
+}
+
+type cborCodec struct{}
+
+func (cborCodec) Marshal(key Key, value interface{}) ([]byte, error) {
+	return cbor.Marshal(value)
+}
+
+func (cborCodec) Unmarshal(key Key, rawData []byte) (interface{}, error) {
+	iface, elem, err := newValueFor(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := cbor.Unmarshal(rawData, iface); err != nil {
+		return nil, err
+	}
+	return unwrapIfNotStruct(iface, elem), nil
+}
@@ -222,6 +222,15 @@ func IsListOptionsLastSegmentPrefix(listOptions ListInterface) bool {
 // of our <Type>Key structs.  Returns nil if the string doesn't match one of
 // our key types.
 func KeyFromDefaultPath(path string) Key {
+	// The hot key shapes (WorkloadEndpoint, v3 resources) are migrated
+	// onto dispatchPathTrie, which scans path without allocating a parts
+	// slice. Anything it doesn't recognize (including a shape it
+	// recognizes the prefix of but rejects, e.g. wrong namespaced-ness)
+	// falls back to the original switch below unchanged.
+	if k := dispatchPathTrie(path); k != nil {
+		return k
+	}
+
 	// "v3" resource keys strictly require a leading slash but older "v1" keys were permissive.
 	// For ease of parsing, strip the slash off now but pass it down to keyFromDefaultPathInner so
 	// it can check for it later.
@@ -395,33 +404,33 @@ func keyFromDefaultPathInner(path string, parts []string) Key {
 			}
 			switch len(parts) {
 			case 6:
-				ri, ok := resourceInfoByPlural[unescapeName(parts[4])]
+				ri, ok := lookupResourceInfo(unescapeName(parts[4]))
 				if !ok {
 					log.Warnf("(BUG) unknown resource type: %v", path)
 					return nil
 				}
-				if namespace.IsNamespaced(ri.kind) {
+				if namespace.IsNamespaced(ri.Kind) {
 					log.Warnf("(BUG) Path is a global resource, but resource is namespaced: %v", path)
 					return nil
 				}
 				log.Debugf("Path is a global resource: %v", path)
 				return ResourceKey{
-					Kind: ri.kind,
+					Kind: ri.Kind,
 					Name: unescapeName(parts[5]),
 				}
 			case 7:
-				ri, ok := resourceInfoByPlural[unescapeName(parts[4])]
+				ri, ok := lookupResourceInfo(unescapeName(parts[4]))
 				if !ok {
 					log.Warnf("(BUG) unknown resource type: %v", path)
 					return nil
 				}
-				if !namespace.IsNamespaced(ri.kind) {
+				if !namespace.IsNamespaced(ri.Kind) {
 					log.Warnf("(BUG) Path is a namespaced resource, but resource is global: %v", path)
 					return nil
 				}
 				log.Debugf("Path is a namespaced resource: %v", path)
 				return ResourceKey{
-					Kind:      ri.kind,
+					Kind:      ri.Kind,
 					Namespace: unescapeName(parts[5]),
 					Name:      unescapeName(parts[6]),
 				}
@@ -460,6 +469,9 @@ func keyFromDefaultPathInner(path string, parts []string) Key {
 			}
 		}
 	}
+	if k := dispatchRegisteredPathHandler(path, parts); k != nil {
+		return k
+	}
 	log.Debugf("Path is unknown: %v", path)
 	return nil
 }
@@ -489,33 +501,33 @@ func OldKeyFromDefaultPath(path string) Key {
 			Name: unescapeName(m[1]),
 		}
 	} else if m := matchGlobalResource.FindStringSubmatch(path); m != nil {
-		ri, ok := resourceInfoByPlural[unescapeName(m[1])]
+		ri, ok := lookupResourceInfo(unescapeName(m[1]))
 		if !ok {
 			log.Warnf("(BUG) unknown resource type: %v", path)
 			return nil
 		}
-		if namespace.IsNamespaced(ri.kind) {
+		if namespace.IsNamespaced(ri.Kind) {
 			log.Warnf("(BUG) Path is a global resource, but resource is namespaced: %v", path)
 			return nil
 		}
 		log.Debugf("Path is a global resource: %v", path)
 		return ResourceKey{
-			Kind: ri.kind,
+			Kind: ri.Kind,
 			Name: unescapeName(m[2]),
 		}
 	} else if m := matchNamespacedResource.FindStringSubmatch(path); m != nil {
-		ri, ok := resourceInfoByPlural[unescapeName(m[1])]
+		ri, ok := lookupResourceInfo(unescapeName(m[1]))
 		if !ok {
 			log.Warnf("(BUG) unknown resource type: %v", path)
 			return nil
 		}
-		if !namespace.IsNamespaced(ri.kind) {
+		if !namespace.IsNamespaced(ri.Kind) {
 			log.Warnf("(BUG) Path is a namespaced resource, but resource is global: %v", path)
 			return nil
 		}
 		log.Debugf("Path is a namespaced resource: %v", path)
 		return ResourceKey{
-			Kind:      resourceInfoByPlural[unescapeName(m[1])].kind,
+			Kind:      lookupResourceInfo(unescapeName(m[1])).Kind,
 			Namespace: unescapeName(m[2]),
 			Name:      unescapeName(m[3]),
 		}
@@ -588,10 +600,12 @@ func OldKeyFromDefaultPath(path string) Key {
 	return nil
 }
 
-// ParseValue parses the default JSON representation of our data into one of
-// our value structs, according to the type of key.  I.e. if passed a
+// ParseValue parses the stored representation of our data into one of our
+// value structs, according to the type of key.  I.e. if passed a
 // PolicyKey as the first parameter, it will try to parse rawData into a
-// Policy struct.
+// Policy struct. The wire encoding (JSON by default, or another
+// ValueCodec registered for valueType via RegisterCodec) is chosen from
+// rawData's magic-byte prefix, if any; see decodeCodecPrefix.
 func ParseValue(key Key, rawData []byte) (interface{}, error) {
 	valueType, err := key.valueType()
 	if err != nil {
@@ -610,15 +624,9 @@ func ParseValue(key Key, rawData []byte) (interface{}, error) {
 		}
 		return &net.IP{IP: ip}, nil
 	}
-	value := reflect.New(valueType)
-	elem := value.Elem()
-	if elem.Kind() == reflect.Struct && elem.NumField() > 0 {
-		if elem.Field(0).Type() == reflect.ValueOf(key).Type() {
-			elem.Field(0).Set(reflect.ValueOf(key))
-		}
-	}
-	iface := value.Interface()
-	err = json.Unmarshal(rawData, iface)
+
+	codec, body := decodeCodecPrefix(rawData)
+	iface, err := codec.Unmarshal(key, body)
 	if err != nil {
 		// This is a special case to address backwards compatibility from the time when we had no state information as block affinity value.
 		// example:
@@ -627,23 +635,18 @@ func ParseValue(key Key, rawData []byte) (interface{}, error) {
 		// In 3.0.7 we added block affinity state as the value, so old "" value is no longer a valid JSON, so for that
 		// particular case we replace the "" with a "{}" so it can be parsed and we don't leak blocks after upgrade to Calico 3.0.7
 		// See: https://github.com/projectcalico/calico/issues/1956
-		if bytes.Equal(rawData, []byte(``)) && valueType == typeBlockAff {
-			rawData = []byte(`{}`)
-			if err = json.Unmarshal(rawData, iface); err != nil {
+		if bytes.Equal(body, []byte(``)) && valueType == typeBlockAff {
+			iface, err = jsonValueCodec.Unmarshal(key, []byte(`{}`))
+			if err != nil {
 				return nil, err
 			}
 		} else {
-			log.Warningf("Failed to unmarshal %#v into value %#v",
-				string(rawData), value)
+			log.Warningf("Failed to unmarshal %#v into value of type %v",
+				string(rawData), valueType)
 			return nil, err
 		}
 	}
 
-	if elem.Kind() != reflect.Struct {
-		// Pointer to a map or slice, unwrap.
-		iface = elem.Interface()
-	}
-
 	if valueType == reflect.TypeOf(apiv3.NetworkPolicy{}) {
 		policy := iface.(*apiv3.NetworkPolicy)
 		policy.Name, policy.Annotations, err = determinePolicyName(policy.Name, policy.Spec.Tier, policy.Annotations)
@@ -680,7 +683,9 @@ func ParseValue(key Key, rawData []byte) (interface{}, error) {
 }
 
 // SerializeValue serializes a value in the model to a []byte to be stored in the datastore.  This
-// performs the opposite processing to ParseValue()
+// performs the opposite processing to ParseValue(). The codec used is
+// whichever ValueCodec is registered for d.Key's valueType (JSON by
+// default); see RegisterCodec.
 func SerializeValue(d *KVPair) ([]byte, error) {
 	valueType, err := d.Key.valueType()
 	if err != nil {
@@ -698,7 +703,13 @@ func SerializeValue(d *KVPair) ([]byte, error) {
 	if valueType == rawIPType {
 		return []byte(fmt.Sprint(d.Value)), nil
 	}
-	return json.Marshal(d.Value)
+
+	codec := codecForType(valueType)
+	body, err := codec.Marshal(d.Key, d.Value)
+	if err != nil {
+		return nil, err
+	}
+	return encodeCodecPrefix(codec, body), nil
 }
 
 // determinePolicyName updates Policy name based on either the projectcalico.org/metadata annotation that was added in 3.30,
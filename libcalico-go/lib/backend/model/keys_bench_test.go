@@ -0,0 +1,62 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+)
+
+// benchPaths is a representative mix of the shapes KeyFromDefaultPath sees
+// in a real cluster: a workload endpoint (the highest-fanout key), a v3
+// namespaced and a v3 global resource, and one shape that isn't migrated
+// onto dispatchPathTrie so the fallback switch stays warm too.
+var benchPaths = []string{
+	"/calico/v1/host/node-1/workload/k8s/default.pod-1/endpoint/eth0",
+	"/calico/resources/v3/projectcalico.org/globalnetworkpolicies/default.allow-all",
+	"/calico/resources/v3/projectcalico.org/networkpolicies/default/default.allow-all",
+	"/calico/v1/config/LogSeverityScreen",
+}
+
+func Benchmark_KeyFromDefaultPath(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, p := range benchPaths {
+			KeyFromDefaultPath(p)
+		}
+	}
+}
+
+// Fuzz_KeyFromDefaultPath checks that the trie-based dispatcher agrees with
+// OldKeyFromDefaultPath on every input, migrated shape or not: a path the
+// trie doesn't recognize should fall through to the same nil/non-nil
+// result the old regex-based implementation gives.
+func Fuzz_KeyFromDefaultPath(f *testing.F) {
+	for _, p := range benchPaths {
+		f.Add(p)
+	}
+	f.Add("/calico/v1/host/node-1/endpoint/eth0")
+	f.Add("not/a/known/path")
+
+	f.Fuzz(func(t *testing.T, path string) {
+		got := KeyFromDefaultPath(path)
+		want := OldKeyFromDefaultPath(path)
+		if (got == nil) != (want == nil) {
+			t.Fatalf("KeyFromDefaultPath(%q) = %#v, OldKeyFromDefaultPath = %#v", path, got, want)
+		}
+		if got != nil && got.String() != want.String() {
+			t.Fatalf("KeyFromDefaultPath(%q) = %v, OldKeyFromDefaultPath = %v", path, got, want)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/calico/libcalico-go/lib/namespace"
+)
+
+// pathCaptures holds the wildcard segments matched while walking the trie,
+// in the order the wildcards were registered. Its size is fixed so that
+// dispatchPathTrie never allocates on the hot path; 4 is enough for every
+// shape migrated onto the trie so far (WorkloadEndpointKey needs the most,
+// at 4).
+type pathCaptures [4]string
+
+// trieNode is one segment of a registered path shape. Each node either
+// matches a literal segment (via children) or a "*" wildcard segment (via
+// wildcard); build is set only on nodes that terminate a registered shape.
+type trieNode struct {
+	children map[string]*trieNode
+	wildcard *trieNode
+	// slot is the pathCaptures index this node's incoming wildcard segment
+	// is stored at. Unused for non-wildcard nodes.
+	slot  int
+	build func(path string, c *pathCaptures) Key
+}
+
+var pathTrieRoot = &trieNode{}
+
+// addPathShape registers a default-path shape with the trie. segments is
+// the literal '/'-delimited shape with wildcard segments spelled "*", e.g.
+// []string{"calico", "v1", "host", "*", "workload", "*", "*", "endpoint", "*"}.
+// build is called with the matched wildcard segments (in registration
+// order, via pathCaptures) once a path matches every segment; it may still
+// return nil to reject the match (e.g. a v3 resource with the wrong
+// namespaced-ness), in which case dispatchPathTrie falls back to nil too.
+func addPathShape(segments []string, build func(path string, c *pathCaptures) Key) {
+	node := pathTrieRoot
+	slot := 0
+	for _, seg := range segments {
+		if seg == "*" {
+			if node.wildcard == nil {
+				node.wildcard = &trieNode{}
+			}
+			node = node.wildcard
+			node.slot = slot
+			slot++
+			continue
+		}
+		if node.children == nil {
+			node.children = map[string]*trieNode{}
+		}
+		child, ok := node.children[seg]
+		if !ok {
+			child = &trieNode{}
+			node.children[seg] = child
+		}
+		node = child
+	}
+	node.build = build
+}
+
+// dispatchPathTrie walks path segment-by-segment (scanning with
+// strings.IndexByte rather than allocating a strings.Split slice) against
+// the registered shapes. It returns nil if path doesn't match any
+// registered shape, or if the matching shape's build func rejects the
+// match; either way the caller should fall back to keyFromDefaultPathInner.
+func dispatchPathTrie(path string) Key {
+	rest := path
+	if strings.HasPrefix(rest, "/") {
+		rest = rest[1:]
+	}
+
+	node := pathTrieRoot
+	var captures pathCaptures
+	for {
+		var seg string
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			seg, rest = rest[:i], rest[i+1:]
+		} else {
+			seg, rest = rest, ""
+		}
+
+		next, ok := node.children[seg]
+		if !ok {
+			if node.wildcard == nil {
+				return nil
+			}
+			next = node.wildcard
+			captures[next.slot] = seg
+		}
+		node = next
+
+		if rest == "" {
+			if node.build == nil {
+				return nil
+			}
+			return node.build(path, &captures)
+		}
+	}
+}
+
+func init() {
+	addPathShape(
+		[]string{"calico", "v1", "host", "*", "workload", "*", "*", "endpoint", "*"},
+		func(path string, c *pathCaptures) Key {
+			return WorkloadEndpointKey{
+				Hostname:       unescapeName(c[0]),
+				OrchestratorID: unescapeName(c[1]),
+				WorkloadID:     unescapeName(c[2]),
+				EndpointID:     unescapeName(c[3]),
+			}
+		},
+	)
+
+	addPathShape(
+		[]string{"calico", "resources", "v3", "projectcalico.org", "*", "*"},
+		func(path string, c *pathCaptures) Key {
+			if path[0] != '/' {
+				return nil
+			}
+			ri, ok := lookupResourceInfo(unescapeName(c[0]))
+			if !ok {
+				log.Warnf("(BUG) unknown resource type: %v", path)
+				return nil
+			}
+			if namespace.IsNamespaced(ri.Kind) {
+				log.Warnf("(BUG) Path is a global resource, but resource is namespaced: %v", path)
+				return nil
+			}
+			log.Debugf("Path is a global resource: %v", path)
+			return ResourceKey{
+				Kind: ri.Kind,
+				Name: unescapeName(c[1]),
+			}
+		},
+	)
+
+	addPathShape(
+		[]string{"calico", "resources", "v3", "projectcalico.org", "*", "*", "*"},
+		func(path string, c *pathCaptures) Key {
+			if path[0] != '/' {
+				return nil
+			}
+			ri, ok := lookupResourceInfo(unescapeName(c[0]))
+			if !ok {
+				log.Warnf("(BUG) unknown resource type: %v", path)
+				return nil
+			}
+			if !namespace.IsNamespaced(ri.Kind) {
+				log.Warnf("(BUG) Path is a namespaced resource, but resource is global: %v", path)
+				return nil
+			}
+			log.Debugf("Path is a namespaced resource: %v", path)
+			return ResourceKey{
+				Kind:      ri.Kind,
+				Namespace: unescapeName(c[1]),
+				Name:      unescapeName(c[2]),
+			}
+		},
+	)
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2025 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"sync"
+
+	apiv3 "github.com/projectcalico/api/pkg/apis/projectcalico/v3"
+)
+
+// ResourceInfo describes a v3 resource kind stored under
+// /calico/resources/v3/<Plural>/..., i.e. what resourceInfoByPlural used
+// to hard-code per kind. It's deliberately just these two fields: any
+// further per-kind behavior (namespaced-ness) is derived from Kind via
+// the namespace package, the same way the built-in kinds already work.
+type ResourceInfo struct {
+	// Kind is the resource's Kind, e.g. "GlobalNetworkPolicy".
+	Kind string
+	// Plural is the lower-case plural path segment under
+	// /calico/resources/v3/, e.g. "globalnetworkpolicies".
+	Plural string
+}
+
+var (
+	resourceRegistryMu   sync.RWMutex
+	resourceInfoByPlural = map[string]ResourceInfo{}
+)
+
+// RegisterResourceKind registers a v3 resource kind so that
+// KeyFromDefaultPath, ListOptionsToDefaultPathRoot, ParseValue and
+// SerializeValue all resolve paths under /calico/resources/v3/<ri.Plural>
+// to a ResourceKey{Kind: ri.Kind}. Built-in kinds self-register from this
+// package's init(); out-of-tree consumers that store their own CRDs
+// alongside Calico's under that same prefix can call this from their own
+// init() to extend these helpers without a change to this package.
+func RegisterResourceKind(ri ResourceInfo) {
+	resourceRegistryMu.Lock()
+	defer resourceRegistryMu.Unlock()
+	resourceInfoByPlural[ri.Plural] = ri
+}
+
+// lookupResourceInfo returns the ResourceInfo registered for plural, if
+// any. It's the read-side counterpart to RegisterResourceKind used by
+// both keyFromDefaultPathInner and dispatchPathTrie's v3-resource shapes.
+func lookupResourceInfo(plural string) (ResourceInfo, bool) {
+	resourceRegistryMu.RLock()
+	defer resourceRegistryMu.RUnlock()
+	ri, ok := resourceInfoByPlural[plural]
+	return ri, ok
+}
+
+// pathHandler parses the default-path representation of a key whose
+// leading segments matched a registered prefix. It returns nil if path
+// doesn't actually match the shape the handler expects.
+type pathHandler func(path string, parts []string) Key
+
+type pathHandlerRegistration struct {
+	prefix  []string
+	handler pathHandler
+}
+
+var (
+	pathHandlersMu sync.RWMutex
+	pathHandlers   []pathHandlerRegistration
+)
+
+// RegisterPathHandler registers parser to run for any default path whose
+// leading segments equal prefix, once keyFromDefaultPathInner's built-in
+// switch finds no match. This is the extension point for key shapes
+// outside /calico/resources/v3/... (where RegisterResourceKind already
+// covers third-party kinds); for example a controller storing its own
+// state under /calico/mycontroller/v1/... can register a handler for
+// prefix []string{"calico", "mycontroller", "v1"}.
+func RegisterPathHandler(prefix []string, parser pathHandler) {
+	pathHandlersMu.Lock()
+	defer pathHandlersMu.Unlock()
+	pathHandlers = append(pathHandlers, pathHandlerRegistration{
+		prefix:  append([]string(nil), prefix...),
+		handler: parser,
+	})
+}
+
+// dispatchRegisteredPathHandler returns the Key produced by the
+// longest-prefix-matching registered handler, or nil if none match.
+func dispatchRegisteredPathHandler(path string, parts []string) Key {
+	pathHandlersMu.RLock()
+	defer pathHandlersMu.RUnlock()
+
+	var best *pathHandlerRegistration
+	for i := range pathHandlers {
+		reg := &pathHandlers[i]
+		if len(reg.prefix) > len(parts) {
+			continue
+		}
+		matched := true
+		for j, seg := range reg.prefix {
+			if parts[j] != seg {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if best == nil || len(reg.prefix) > len(best.prefix) {
+			best = reg
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.handler(path, parts)
+}
+
+// init self-registers the resource kinds that ParseValue already knows
+// how to apply name-defaulting to, so behavior for built-in kinds is
+// unchanged now that resourceInfoByPlural is populated via registration
+// instead of a literal map.
+func init() {
+	RegisterResourceKind(ResourceInfo{Kind: apiv3.KindNetworkPolicy, Plural: "networkpolicies"})
+	RegisterResourceKind(ResourceInfo{Kind: apiv3.KindGlobalNetworkPolicy, Plural: "globalnetworkpolicies"})
+	RegisterResourceKind(ResourceInfo{Kind: apiv3.KindStagedNetworkPolicy, Plural: "stagednetworkpolicies"})
+	RegisterResourceKind(ResourceInfo{Kind: apiv3.KindStagedGlobalNetworkPolicy, Plural: "stagedglobalnetworkpolicies"})
+}
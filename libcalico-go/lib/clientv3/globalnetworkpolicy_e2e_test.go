@@ -33,6 +33,7 @@ import (
 	"github.com/projectcalico/calico/libcalico-go/lib/names"
 	"github.com/projectcalico/calico/libcalico-go/lib/options"
 	"github.com/projectcalico/calico/libcalico-go/lib/testutils"
+	"github.com/projectcalico/calico/libcalico-go/lib/testutils/reachability"
 	"github.com/projectcalico/calico/libcalico-go/lib/watch"
 )
 
@@ -634,4 +635,51 @@ var _ = testutils.E2eDatastoreDescribe("GlobalNetworkPolicy tests", testutils.Da
 			testWatcher4.Stop()
 		})
 	})
+
+	Describe("with a GlobalNetworkPolicy modelled for reachability", func() {
+		It("should compute an expected truth table matching the created policy's intent", func() {
+			By("Creating a policy that only allows ingress from role=client to role=server")
+			name := "globalnetworkp-reachability"
+			spec := apiv3.GlobalNetworkPolicySpec{
+				Selector: "role == 'server'",
+				Types:    []apiv3.PolicyType{apiv3.PolicyTypeIngress},
+				Ingress: []apiv3.Rule{{
+					Action: apiv3.Allow,
+					Source: apiv3.EntityRule{Selector: "role == 'client'"},
+				}},
+			}
+			res, outError := c.GlobalNetworkPolicies().Create(
+				ctx,
+				&apiv3.GlobalNetworkPolicy{
+					ObjectMeta: metav1.ObjectMeta{Name: name},
+					Spec:       spec,
+				},
+				options.SetOptions{},
+			)
+			Expect(outError).NotTo(HaveOccurred())
+			defer func() {
+				_, _ = c.GlobalNetworkPolicies().Delete(ctx, name, options.DeleteOptions{})
+			}()
+
+			By("Reading the policy back and computing the expected truth table from it")
+			res, outError = c.GlobalNetworkPolicies().Get(ctx, name, options.GetOptions{})
+			Expect(outError).NotTo(HaveOccurred())
+
+			matrix := reachability.Matrix{Pods: []reachability.Pod{
+				{Namespace: "ns1", Name: "client", Labels: map[string]string{"role": "client"}},
+				{Namespace: "ns1", Name: "server", Labels: map[string]string{"role": "server"}},
+				{Namespace: "ns1", Name: "other", Labels: map[string]string{"role": "other"}},
+			}}
+			table := reachability.ExpectedTable([]*apiv3.GlobalNetworkPolicy{res}, matrix, 80, reachability.ProtocolTCP)
+
+			Expect(table.Get("ns1/client", "ns1/server")).To(BeTrue())
+			Expect(table.Get("ns1/other", "ns1/server")).To(BeFalse())
+
+			// Driving reachability.RunMatrix against this table requires a
+			// live probe mesh (pods actually running the policy's
+			// dataplane), which this datastore-only e2e suite doesn't
+			// stand up; that wiring belongs to the deployment that runs
+			// this package's probes against a real cluster.
+		})
+	})
 })
@@ -0,0 +1,240 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reachability
+
+import (
+	"sort"
+	"strings"
+
+	apiv3 "github.com/projectcalico/api/pkg/apis/projectcalico/v3"
+
+	"github.com/projectcalico/calico/libcalico-go/lib/selector"
+)
+
+// direction is which half of a flow a GlobalNetworkPolicy's rules are
+// being evaluated for: egress at the sending pod, or ingress at the
+// receiving one.
+type direction int
+
+const (
+	directionEgress direction = iota
+	directionIngress
+)
+
+// ExpectedTable computes the reachability TruthTable that policies should
+// produce across matrix at port/protocol: for every ordered (from, to)
+// pair, egress is evaluated against "from" and ingress against "to", and
+// the flow is only expected to succeed if both allow it -- the same two-
+// sided evaluation Calico's dataplane performs.
+//
+// This is a deliberately scoped-down re-implementation of policy
+// evaluation: a single (untiered) ordered list of GlobalNetworkPolicies,
+// Source/Destination selector, NotSelector, Nets, NotNets and port
+// matching, and Allow/Deny/Pass/Log actions. It does not model ICMP rules,
+// namespace/service-account selectors, per-Tier default actions, or
+// HostEndpoints -- callers with policies that depend on those should treat
+// ExpectedTable's output as a starting point, not ground truth.
+func ExpectedTable(policies []*apiv3.GlobalNetworkPolicy, matrix Matrix, port int, protocol Protocol) *TruthTable {
+	ordered := orderedPolicies(policies)
+	table := NewTruthTable(matrix.Keys())
+	for _, from := range matrix.Pods {
+		for _, to := range matrix.Pods {
+			allowed := evaluateDirection(ordered, from, to, port, protocol, directionEgress) &&
+				evaluateDirection(ordered, to, from, port, protocol, directionIngress)
+			table.Set(from.Key(), to.Key(), allowed)
+		}
+	}
+	return table
+}
+
+// orderedPolicies sorts policies the way Calico evaluates them within a
+// tier: ascending Spec.Order, with unordered (nil Order) policies
+// evaluated last, in input order.
+func orderedPolicies(policies []*apiv3.GlobalNetworkPolicy) []*apiv3.GlobalNetworkPolicy {
+	ordered := make([]*apiv3.GlobalNetworkPolicy, len(policies))
+	copy(ordered, policies)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		oi, oj := ordered[i].Spec.Order, ordered[j].Spec.Order
+		if oi == nil {
+			return false
+		}
+		if oj == nil {
+			return true
+		}
+		return *oi < *oj
+	})
+	return ordered
+}
+
+// evaluateDirection resolves whether a flow between local and peer is
+// allowed from local's point of view for dir, walking the policies that
+// select local for dir in order until some rule in one of them matches.
+func evaluateDirection(policies []*apiv3.GlobalNetworkPolicy, local, peer Pod, port int, protocol Protocol, dir direction) bool {
+	isolated := false
+
+policyLoop:
+	for _, p := range policies {
+		if !matchSelector(p.Spec.Selector, local.Labels) {
+			continue
+		}
+		if !appliesToDirection(p.Spec, dir) {
+			continue
+		}
+		isolated = true
+
+		rules := p.Spec.Ingress
+		if dir == directionEgress {
+			rules = p.Spec.Egress
+		}
+		for _, rule := range rules {
+			if !ruleMatches(rule, local, peer, port, protocol, dir) {
+				continue
+			}
+			switch rule.Action {
+			case apiv3.Allow:
+				return true
+			case apiv3.Deny:
+				return false
+			case apiv3.Pass:
+				// Pass hands evaluation to the next policy, abandoning
+				// this policy's remaining rules.
+				continue policyLoop
+			default:
+				// Log doesn't resolve the flow; keep checking this
+				// policy's remaining rules.
+			}
+		}
+	}
+
+	// No policy's rules resolved the flow: isolated pods (selected by at
+	// least one policy for this direction) default-deny; unselected ones
+	// are unrestricted.
+	return !isolated
+}
+
+// appliesToDirection reports whether spec applies to dir, inferring the
+// default Types the same way Calico does when Types is unset: whichever of
+// Ingress/Egress has rules, or both if the policy has neither (or both).
+func appliesToDirection(spec apiv3.GlobalNetworkPolicySpec, dir direction) bool {
+	types := spec.Types
+	if len(types) == 0 {
+		switch {
+		case len(spec.Ingress) > 0 && len(spec.Egress) == 0:
+			types = []apiv3.PolicyType{apiv3.PolicyTypeIngress}
+		case len(spec.Egress) > 0 && len(spec.Ingress) == 0:
+			types = []apiv3.PolicyType{apiv3.PolicyTypeEgress}
+		default:
+			types = []apiv3.PolicyType{apiv3.PolicyTypeIngress, apiv3.PolicyTypeEgress}
+		}
+	}
+
+	want := apiv3.PolicyTypeIngress
+	if dir == directionEgress {
+		want = apiv3.PolicyTypeEgress
+	}
+	for _, t := range types {
+		if t == want {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleMatches reports whether rule matches a flow between local and peer
+// at port/protocol, evaluated from local's side for dir: for egress, local
+// is the rule's Source and peer its Destination; for ingress, the reverse.
+func ruleMatches(rule apiv3.Rule, local, peer Pod, port int, protocol Protocol, dir direction) bool {
+	src, dst := peer, local
+	if dir == directionEgress {
+		src, dst = local, peer
+	}
+	if !entityMatches(rule.Source, src) {
+		return false
+	}
+	if !entityMatches(rule.Destination, dst) {
+		return false
+	}
+	if !protocolMatches(rule, protocol) {
+		return false
+	}
+	return portsMatch(rule.Destination, port)
+}
+
+// entityMatches reports whether pod satisfies entity's selector/not-
+// selector/nets/not-nets clauses. An empty clause matches anything.
+func entityMatches(entity apiv3.EntityRule, pod Pod) bool {
+	if !matchSelector(entity.Selector, pod.Labels) {
+		return false
+	}
+	if entity.NotSelector != "" {
+		parsed, err := selector.Parse(entity.NotSelector)
+		if err == nil && parsed.Evaluate(pod.Labels) {
+			return false
+		}
+	}
+	if len(entity.Nets) > 0 && !ipInNets(pod.IP, entity.Nets) {
+		return false
+	}
+	if len(entity.NotNets) > 0 && ipInNets(pod.IP, entity.NotNets) {
+		return false
+	}
+	return true
+}
+
+// matchSelector returns true if sel is empty (matches any labels) or sel
+// evaluates to true against labels. An unparsable selector never matches.
+func matchSelector(sel string, labels map[string]string) bool {
+	if sel == "" {
+		return true
+	}
+	parsed, err := selector.Parse(sel)
+	if err != nil {
+		return false
+	}
+	return parsed.Evaluate(labels)
+}
+
+// protocolMatches reports whether rule's Protocol (if any) names protocol.
+// A rule with no Protocol set matches every protocol.
+func protocolMatches(rule apiv3.Rule, protocol Protocol) bool {
+	if rule.Protocol == nil {
+		return true
+	}
+	return strings.EqualFold(rule.Protocol.String(), string(protocol))
+}
+
+// portsMatch reports whether entity's Ports/NotPorts clauses admit port.
+// Empty Ports matches any port; a non-empty NotPorts excludes the ports it
+// lists regardless of what Ports allows.
+func portsMatch(entity apiv3.EntityRule, port int) bool {
+	if len(entity.Ports) > 0 {
+		found := false
+		for _, r := range entity.Ports {
+			if port >= int(r.MinPort) && port <= int(r.MaxPort) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, r := range entity.NotPorts {
+		if port >= int(r.MinPort) && port <= int(r.MaxPort) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reachability
+
+import (
+	"net"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apiv3 "github.com/projectcalico/api/pkg/apis/projectcalico/v3"
+
+	"github.com/projectcalico/calico/libcalico-go/lib/numorstring"
+)
+
+func testMatrix() Matrix {
+	return Matrix{Pods: []Pod{
+		{Namespace: "ns1", Name: "client", Labels: map[string]string{"role": "client"}, IP: net.ParseIP("10.0.0.1")},
+		{Namespace: "ns1", Name: "server", Labels: map[string]string{"role": "server"}, IP: net.ParseIP("10.0.0.2")},
+		{Namespace: "ns2", Name: "other", Labels: map[string]string{"role": "other"}, IP: net.ParseIP("10.0.0.3")},
+	}}
+}
+
+func TestExpectedTableNoPoliciesIsFullyOpen(t *testing.T) {
+	RegisterTestingT(t)
+
+	matrix := testMatrix()
+	table := ExpectedTable(nil, matrix, 80, ProtocolTCP)
+	for _, from := range matrix.Keys() {
+		for _, to := range matrix.Keys() {
+			Expect(table.Get(from, to)).To(BeTrue(), "%s -> %s", from, to)
+		}
+	}
+}
+
+func TestExpectedTableIngressIsolationDefaultDenies(t *testing.T) {
+	RegisterTestingT(t)
+
+	matrix := testMatrix()
+	policy := &apiv3.GlobalNetworkPolicy{Spec: apiv3.GlobalNetworkPolicySpec{
+		Selector: "role == 'server'",
+		Types:    []apiv3.PolicyType{apiv3.PolicyTypeIngress},
+		// No Ingress rules: the policy selects server for ingress but
+		// never allows anything in, so server becomes ingress-isolated.
+	}}
+	table := ExpectedTable([]*apiv3.GlobalNetworkPolicy{policy}, matrix, 80, ProtocolTCP)
+
+	Expect(table.Get("ns1/client", "ns1/server")).To(BeFalse())
+	Expect(table.Get("ns2/other", "ns1/server")).To(BeFalse())
+	// Unselected pods remain unrestricted.
+	Expect(table.Get("ns1/client", "ns2/other")).To(BeTrue())
+}
+
+func TestExpectedTableAllowRuleBySelector(t *testing.T) {
+	RegisterTestingT(t)
+
+	matrix := testMatrix()
+	policy := &apiv3.GlobalNetworkPolicy{Spec: apiv3.GlobalNetworkPolicySpec{
+		Selector: "role == 'server'",
+		Types:    []apiv3.PolicyType{apiv3.PolicyTypeIngress},
+		Ingress: []apiv3.Rule{{
+			Action: apiv3.Allow,
+			Source: apiv3.EntityRule{Selector: "role == 'client'"},
+		}},
+	}}
+	table := ExpectedTable([]*apiv3.GlobalNetworkPolicy{policy}, matrix, 80, ProtocolTCP)
+
+	Expect(table.Get("ns1/client", "ns1/server")).To(BeTrue())
+	Expect(table.Get("ns2/other", "ns1/server")).To(BeFalse())
+}
+
+func TestExpectedTablePassFallsThroughToNextPolicy(t *testing.T) {
+	RegisterTestingT(t)
+
+	matrix := testMatrix()
+	order1, order2 := float64(1), float64(2)
+	passPolicy := &apiv3.GlobalNetworkPolicy{Spec: apiv3.GlobalNetworkPolicySpec{
+		Order:    &order1,
+		Selector: "role == 'server'",
+		Types:    []apiv3.PolicyType{apiv3.PolicyTypeIngress},
+		Ingress: []apiv3.Rule{{
+			Action: apiv3.Pass,
+			Source: apiv3.EntityRule{Selector: "role == 'other'"},
+		}},
+	}}
+	allowPolicy := &apiv3.GlobalNetworkPolicy{Spec: apiv3.GlobalNetworkPolicySpec{
+		Order:    &order2,
+		Selector: "role == 'server'",
+		Types:    []apiv3.PolicyType{apiv3.PolicyTypeIngress},
+		Ingress: []apiv3.Rule{{
+			Action: apiv3.Allow,
+			Source: apiv3.EntityRule{Selector: "role == 'other'"},
+		}},
+	}}
+	table := ExpectedTable([]*apiv3.GlobalNetworkPolicy{passPolicy, allowPolicy}, matrix, 80, ProtocolTCP)
+
+	Expect(table.Get("ns2/other", "ns1/server")).To(BeTrue())
+}
+
+func TestExpectedTablePortRestriction(t *testing.T) {
+	RegisterTestingT(t)
+
+	matrix := testMatrix()
+	policy := &apiv3.GlobalNetworkPolicy{Spec: apiv3.GlobalNetworkPolicySpec{
+		Selector: "role == 'server'",
+		Types:    []apiv3.PolicyType{apiv3.PolicyTypeIngress},
+		Ingress: []apiv3.Rule{{
+			Action:      apiv3.Allow,
+			Source:      apiv3.EntityRule{Selector: "role == 'client'"},
+			Destination: apiv3.EntityRule{Ports: []numorstring.Port{{MinPort: 443, MaxPort: 443}}},
+		}},
+	}}
+	tableOnPort443 := ExpectedTable([]*apiv3.GlobalNetworkPolicy{policy}, matrix, 443, ProtocolTCP)
+	tableOnPort80 := ExpectedTable([]*apiv3.GlobalNetworkPolicy{policy}, matrix, 80, ProtocolTCP)
+
+	Expect(tableOnPort443.Get("ns1/client", "ns1/server")).To(BeTrue())
+	Expect(tableOnPort80.Get("ns1/client", "ns1/server")).To(BeFalse())
+}
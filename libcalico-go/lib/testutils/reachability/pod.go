@@ -0,0 +1,68 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reachability computes expected GlobalNetworkPolicy reachability
+// for a model of pods, drives a real N x N probe mesh against it, and
+// diffs the two -- the same "truth table" approach the upstream Kubernetes
+// NetworkPolicy conformance suite (test/e2e/network/netpol) uses, adapted
+// to Calico's tiered/global policy API.
+package reachability
+
+import "net"
+
+// Pod is a minimal description of a workload endpoint: just enough for
+// reachability modelling to evaluate GlobalNetworkPolicy selectors and
+// rules against it, and for the probe harness to dial it.
+type Pod struct {
+	Namespace string
+	Name      string
+	Labels    map[string]string
+	IP        net.IP
+}
+
+// Key identifies a Pod in a TruthTable's rows/columns, as "namespace/name".
+func (p Pod) Key() string {
+	return p.Namespace + "/" + p.Name
+}
+
+// Matrix is the "namespaces x pods" model a reachability run is computed
+// and probed against.
+type Matrix struct {
+	Pods []Pod
+}
+
+// Keys returns every pod's TruthTable key, in Matrix order.
+func (m Matrix) Keys() []string {
+	keys := make([]string, len(m.Pods))
+	for i, p := range m.Pods {
+		keys[i] = p.Key()
+	}
+	return keys
+}
+
+// ipInNets returns true if ip falls within at least one of cidrs. A CIDR
+// that fails to parse never matches, but doesn't abort checking the rest
+// of the list.
+func ipInNets(ip net.IP, cidrs []string) bool {
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
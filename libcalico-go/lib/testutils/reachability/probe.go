@@ -0,0 +1,156 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reachability
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Protocol is an L4 protocol a probe mesh listens on.
+type Protocol string
+
+const (
+	ProtocolTCP  Protocol = "tcp"
+	ProtocolUDP  Protocol = "udp"
+	ProtocolSCTP Protocol = "sctp"
+)
+
+// ProbeRunner drives a single connectivity probe from src to dst:port over
+// protocol, reporting whether it succeeded. Implementations are expected
+// to report (false, nil) for a clean refusal/timeout (the normal "denied
+// by policy" outcome) and reserve a non-nil error for the probe mechanism
+// itself failing (e.g. the probe pod is unreachable).
+type ProbeRunner interface {
+	Probe(ctx context.Context, src, dst Pod, port int, protocol Protocol) (bool, error)
+}
+
+// DialProbeRunner is a ProbeRunner that dials dst's IP:port directly with
+// net.Dial. It ignores src: the dial is made from wherever this process
+// runs, so for the result to reflect src's policy the caller must itself
+// be running inside src's probe pod (e.g. via a kubectl exec'd client
+// binary), the same way the probe pods this package's consumers deploy
+// are expected to work.
+type DialProbeRunner struct {
+	// Timeout bounds each dial attempt. Defaults to 2 seconds.
+	Timeout time.Duration
+}
+
+func (d DialProbeRunner) Probe(ctx context.Context, src, dst Pod, port int, protocol Protocol) (bool, error) {
+	if protocol == ProtocolSCTP {
+		return false, fmt.Errorf("reachability: SCTP probing is not supported by net.Dial; use an external probe binary")
+	}
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	addr := net.JoinHostPort(dst.IP.String(), strconv.Itoa(port))
+	conn, err := dialer.DialContext(ctx, string(protocol), addr)
+	if err != nil {
+		// A refused/timed-out dial is the expected shape of "denied by
+		// policy", not a harness failure.
+		return false, nil
+	}
+	_ = conn.Close()
+	return true, nil
+}
+
+// RetryConfig controls how RunMatrix re-probes a cell whose observed
+// result disagrees with the expected TruthTable, to tolerate policy
+// programming latency between the probe mesh coming up and the dataplane
+// catching up with a just-created/updated policy.
+type RetryConfig struct {
+	// Attempts is the maximum number of times to probe a single cell.
+	// Defaults to 1 (no retries) if <= 0.
+	Attempts int
+	// Backoff is the delay between attempts. Defaults to 1 second if <= 0.
+	Backoff time.Duration
+}
+
+func (r RetryConfig) orDefault() RetryConfig {
+	if r.Attempts <= 0 {
+		r.Attempts = 1
+	}
+	if r.Backoff <= 0 {
+		r.Backoff = time.Second
+	}
+	return r
+}
+
+// RunMatrix drives an N x N probe -- every pod in matrix to every other
+// pod, including itself -- at port/protocol, in parallel via runner, and
+// returns the observed TruthTable. If want is non-nil, a cell whose
+// outcome disagrees with it is retried (with backoff) up to retry's
+// attempt count before being recorded, so a flaky window right after
+// policy programming doesn't get reported as a real mismatch; pass a nil
+// want to just record what was observed with no retries.
+//
+// The first error any probe reports (the probe mechanism failing, not a
+// clean denial) is returned alongside whatever table was gathered.
+func RunMatrix(ctx context.Context, matrix Matrix, runner ProbeRunner, port int, protocol Protocol, retry RetryConfig, want *TruthTable) (*TruthTable, error) {
+	retry = retry.orDefault()
+	got := NewTruthTable(matrix.Keys())
+
+	type cellResult struct {
+		from, to string
+		allowed  bool
+		err      error
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan cellResult, len(matrix.Pods)*len(matrix.Pods))
+	for _, src := range matrix.Pods {
+		for _, dst := range matrix.Pods {
+			src, dst := src, dst
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				var allowed bool
+				var err error
+				for attempt := 0; attempt < retry.Attempts; attempt++ {
+					allowed, err = runner.Probe(ctx, src, dst, port, protocol)
+					if err != nil {
+						break
+					}
+					if want == nil || allowed == want.Get(src.Key(), dst.Key()) {
+						break
+					}
+					time.Sleep(retry.Backoff)
+				}
+				results <- cellResult{from: src.Key(), to: dst.Key(), allowed: allowed, err: err}
+			}()
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var firstErr error
+	for r := range results {
+		if r.err != nil && firstErr == nil {
+			firstErr = r.err
+		}
+		got.Set(r.from, r.to, r.allowed)
+	}
+	return got, firstErr
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reachability
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+// fakeRunner reports the outcome table's result for cell (src,dst), for
+// as long as attemptsBeforeFlip says the real probe would still be stale,
+// so tests can exercise RunMatrix's retry-until-it-matches behaviour
+// without a network.
+type fakeRunner struct {
+	stale              *TruthTable
+	fresh              *TruthTable
+	attemptsBeforeFlip int32
+	attemptsSeen       int32
+}
+
+func (f *fakeRunner) Probe(ctx context.Context, src, dst Pod, port int, protocol Protocol) (bool, error) {
+	n := atomic.AddInt32(&f.attemptsSeen, 1)
+	if n <= f.attemptsBeforeFlip {
+		return f.stale.Get(src.Key(), dst.Key()), nil
+	}
+	return f.fresh.Get(src.Key(), dst.Key()), nil
+}
+
+func TestRunMatrixRecordsObservedResults(t *testing.T) {
+	RegisterTestingT(t)
+
+	matrix := Matrix{Pods: []Pod{
+		{Namespace: "ns1", Name: "a"},
+		{Namespace: "ns1", Name: "b"},
+	}}
+	want := NewTruthTable(matrix.Keys())
+	want.Set("ns1/a", "ns1/b", true)
+	want.Set("ns1/b", "ns1/a", false)
+
+	runner := &fakeRunner{stale: want, fresh: want, attemptsBeforeFlip: 0}
+	got, err := RunMatrix(context.Background(), matrix, runner, 80, ProtocolTCP, RetryConfig{}, nil)
+	Expect(err).NotTo(HaveOccurred())
+
+	_, mismatches := got.Compare(want)
+	Expect(mismatches).To(Equal(0))
+}
+
+func TestRunMatrixRetriesUntilItMatchesWant(t *testing.T) {
+	RegisterTestingT(t)
+
+	matrix := Matrix{Pods: []Pod{
+		{Namespace: "ns1", Name: "a"},
+		{Namespace: "ns1", Name: "b"},
+	}}
+	stale := NewTruthTable(matrix.Keys()) // everything denied
+	fresh := NewTruthTable(matrix.Keys())
+	fresh.Set("ns1/a", "ns1/b", true)
+	fresh.Set("ns1/b", "ns1/a", true)
+	fresh.Set("ns1/a", "ns1/a", true)
+	fresh.Set("ns1/b", "ns1/b", true)
+
+	runner := &fakeRunner{stale: stale, fresh: fresh, attemptsBeforeFlip: 1}
+	got, err := RunMatrix(context.Background(), matrix, runner, 80, ProtocolTCP,
+		RetryConfig{Attempts: 3, Backoff: time.Millisecond}, fresh)
+	Expect(err).NotTo(HaveOccurred())
+
+	_, mismatches := got.Compare(fresh)
+	Expect(mismatches).To(Equal(0))
+}
+
+func TestRunMatrixPropagatesProbeError(t *testing.T) {
+	RegisterTestingT(t)
+
+	matrix := Matrix{Pods: []Pod{{Namespace: "ns1", Name: "a"}}}
+	runner := errorRunner{}
+	_, err := RunMatrix(context.Background(), matrix, runner, 80, ProtocolSCTP, RetryConfig{}, nil)
+	Expect(err).To(HaveOccurred())
+}
+
+type errorRunner struct{}
+
+func (errorRunner) Probe(ctx context.Context, src, dst Pod, port int, protocol Protocol) (bool, error) {
+	return DialProbeRunner{}.Probe(ctx, src, dst, port, protocol)
+}
+
+func TestDialProbeRunnerRejectsSCTP(t *testing.T) {
+	RegisterTestingT(t)
+
+	d := DialProbeRunner{}
+	_, err := d.Probe(context.Background(), Pod{}, Pod{IP: net.ParseIP("127.0.0.1")}, 80, ProtocolSCTP)
+	Expect(err).To(HaveOccurred())
+}
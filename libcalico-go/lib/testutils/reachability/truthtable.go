@@ -0,0 +1,93 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reachability
+
+import "strings"
+
+// TruthTable is a square grid of allowed/denied outcomes between every pod
+// in a Matrix, indexed by Pod.Key(). It holds both the expected table
+// ExpectedTable computes from policy and the observed table RunMatrix
+// fills in from real probes, so the two can be diffed with Compare.
+type TruthTable struct {
+	items  []string
+	index  map[string]int
+	values [][]bool
+}
+
+// NewTruthTable creates a TruthTable over items (typically Matrix.Keys()),
+// with every cell defaulting to false (denied) until Set.
+func NewTruthTable(items []string) *TruthTable {
+	index := make(map[string]int, len(items))
+	values := make([][]bool, len(items))
+	for i, item := range items {
+		index[item] = i
+		values[i] = make([]bool, len(items))
+	}
+	return &TruthTable{items: items, index: index, values: values}
+}
+
+// Set records whether traffic from "from" to "to" is allowed.
+func (tt *TruthTable) Set(from, to string, allowed bool) {
+	fi, ok := tt.index[from]
+	if !ok {
+		return
+	}
+	ti, ok := tt.index[to]
+	if !ok {
+		return
+	}
+	tt.values[fi][ti] = allowed
+}
+
+// Get returns whether traffic from "from" to "to" was recorded as allowed.
+// Unknown from/to pairs report false.
+func (tt *TruthTable) Get(from, to string) bool {
+	fi, ok := tt.index[from]
+	if !ok {
+		return false
+	}
+	ti, ok := tt.index[to]
+	if !ok {
+		return false
+	}
+	return tt.values[fi][ti]
+}
+
+// Compare diffs tt (observed) against want (expected) cell by cell. The
+// returned grid has one row per "from" pod and one column per "to" pod:
+// '.' where the two agree, '+' where tt allows traffic want expected
+// denied, and '-' where tt denies traffic want expected to be allowed. The
+// second return value is the number of disagreeing cells, so callers can
+// tell "matches" (0) from "still diverging" without reparsing the grid.
+func (tt *TruthTable) Compare(want *TruthTable) (grid string, mismatches int) {
+	var b strings.Builder
+	for _, from := range tt.items {
+		for _, to := range tt.items {
+			got, expected := tt.Get(from, to), want.Get(from, to)
+			switch {
+			case got == expected:
+				b.WriteByte('.')
+			case got && !expected:
+				b.WriteByte('+')
+				mismatches++
+			default:
+				b.WriteByte('-')
+				mismatches++
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String(), mismatches
+}
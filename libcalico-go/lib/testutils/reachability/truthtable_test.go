@@ -0,0 +1,60 @@
+// Copyright (c) 2018-2025 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reachability
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestTruthTableSetAndGet(t *testing.T) {
+	RegisterTestingT(t)
+
+	tt := NewTruthTable([]string{"a", "b"})
+	Expect(tt.Get("a", "b")).To(BeFalse())
+
+	tt.Set("a", "b", true)
+	Expect(tt.Get("a", "b")).To(BeTrue())
+	Expect(tt.Get("b", "a")).To(BeFalse())
+}
+
+func TestTruthTableGetUnknownKeyIsFalse(t *testing.T) {
+	RegisterTestingT(t)
+
+	tt := NewTruthTable([]string{"a"})
+	Expect(tt.Get("a", "nope")).To(BeFalse())
+	Expect(tt.Get("nope", "a")).To(BeFalse())
+}
+
+func TestTruthTableCompare(t *testing.T) {
+	RegisterTestingT(t)
+
+	want := NewTruthTable([]string{"a", "b"})
+	want.Set("a", "a", true)
+	want.Set("a", "b", true)
+	want.Set("b", "a", false)
+	want.Set("b", "b", true)
+
+	got := NewTruthTable([]string{"a", "b"})
+	got.Set("a", "a", true)  // agrees
+	got.Set("a", "b", false) // disagrees: want allowed, got denied -> '-'
+	got.Set("b", "a", true)  // disagrees: want denied, got allowed -> '+'
+	got.Set("b", "b", true)  // agrees
+
+	grid, mismatches := got.Compare(want)
+	Expect(mismatches).To(Equal(2))
+	Expect(grid).To(Equal(".-\n+.\n"))
+}